@@ -0,0 +1,115 @@
+package teta
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+type AccessLogConfig struct {
+	Skipper Skipper
+	// SkipPaths lists exact request paths that are never logged, such as
+	// health and readiness probes.
+	SkipPaths []string
+	// Level picks the log level for a response status. By default 5xx is
+	// logged as error, 4xx as warning and everything else as info.
+	Level func(status int) slog.Level
+	// SampleRate is the fraction of requests below the warning level that
+	// get logged. Values outside (0, 1) log every request.
+	SampleRate float64
+	// Fields returns extra key-value pairs to add to the entry.
+	Fields func(c *Context) []any
+}
+
+var DefaultAccessLogConfig = AccessLogConfig{
+	Skipper: DefaultSkipper,
+	Level:   DefaultAccessLogLevel,
+}
+
+func DefaultAccessLogLevel(status int) slog.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return slog.LevelError
+	case status >= http.StatusBadRequest:
+		return slog.LevelWarn
+	}
+	return slog.LevelInfo
+}
+
+func AccessLog() Middleware {
+	return AccessLogWithConfig(DefaultAccessLogConfig)
+}
+
+// AccessLogWithConfig logs one line per request through Context.Logger, which
+// already carries the request ID, method, route and user.
+// Handler errors are rendered with Context.Error first, so the logged status
+// is the one the client receives.
+func AccessLogWithConfig(config AccessLogConfig) Middleware {
+	if config.Skipper == nil {
+		config.Skipper = DefaultAccessLogConfig.Skipper
+	}
+	if config.Level == nil {
+		config.Level = DefaultAccessLogConfig.Level
+	}
+
+	skipPaths := make(map[string]struct{}, len(config.SkipPaths))
+	for _, p := range config.SkipPaths {
+		skipPaths[p] = struct{}{}
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			if _, ok := skipPaths[c.Request.URL.Path]; ok || config.Skipper(c) {
+				return next(c)
+			}
+
+			start := time.Now()
+
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			res := c.Response()
+			level := config.Level(res.Status)
+			if level < slog.LevelWarn && config.SampleRate > 0 && config.SampleRate < 1 &&
+				rand.Float64() >= config.SampleRate {
+				return err
+			}
+
+			r := c.Request
+			fields := []any{
+				"path", r.URL.Path,
+				"status", res.Status,
+				"bytes", res.Size,
+				"latency", time.Since(start),
+				"ip", c.RealIP(),
+				"user_agent", r.UserAgent(),
+			}
+			if err != nil {
+				fields = append(fields, "error", err.Error())
+			}
+			if config.Fields != nil {
+				fields = append(fields, config.Fields(c)...)
+			}
+
+			logAtLevel(c.Logger(), level, "request", fields...)
+
+			return err
+		}
+	}
+}
+
+func logAtLevel(l Logger, level slog.Level, msg string, args ...any) {
+	switch {
+	case level >= slog.LevelError:
+		l.Error(msg, args...)
+	case level >= slog.LevelWarn:
+		l.Warn(msg, args...)
+	case level >= slog.LevelInfo:
+		l.Info(msg, args...)
+	default:
+		l.Debug(msg, args...)
+	}
+}
@@ -0,0 +1,200 @@
+package teta
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrUnauthorized is returned by auth validators for unknown credentials.
+// The middleware answers it, and any other 401 HTTPError, with a challenge.
+var ErrUnauthorized = NewHTTPError(http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+
+// PrincipalKey holds what the auth validator returned for the request. A
+// string or fmt.Stringer principal also sets UserKey for the request logs.
+var PrincipalKey = NewKey[any]("principal")
+
+type (
+	BasicAuthValidator func(c *Context, username, password string) (any, error)
+	BearerValidator    func(c *Context, token string) (any, error)
+	APIKeyValidator    func(c *Context, key string) (any, error)
+)
+
+type BasicAuthConfig struct {
+	Skipper   Skipper
+	Validator BasicAuthValidator
+	Realm     string
+}
+
+type BearerAuthConfig struct {
+	Skipper   Skipper
+	Validator BearerValidator
+	Realm     string
+}
+
+type APIKeyConfig struct {
+	Skipper   Skipper
+	Validator APIKeyValidator
+	// KeyLookup lists where the key is sent, as comma separated
+	// "header:<name>", "query:<param>" or "cookie:<name>" sources.
+	KeyLookup string
+	Realm     string
+}
+
+const defaultAuthRealm = "Restricted"
+
+var DefaultAPIKeyConfig = APIKeyConfig{
+	Skipper:   DefaultSkipper,
+	KeyLookup: "header:X-API-Key",
+	Realm:     defaultAuthRealm,
+}
+
+func BasicAuth(fn BasicAuthValidator) Middleware {
+	return BasicAuthWithConfig(BasicAuthConfig{Validator: fn})
+}
+
+func BasicAuthWithConfig(config BasicAuthConfig) Middleware {
+	if config.Validator == nil {
+		panic("teta: basic auth requires a validator")
+	}
+	challenge := `Basic realm=` + strconv.Quote(realmOr(config.Realm)) + `, charset="UTF-8"`
+
+	return authMiddleware(config.Skipper, func(c *Context) (any, string, error) {
+		username, password, ok := c.Request.BasicAuth()
+		if !ok {
+			return nil, challenge, ErrUnauthorized
+		}
+
+		principal, err := config.Validator(c, username, password)
+		return principal, challenge, err
+	})
+}
+
+// BasicAuthUsers returns a validator checking against a fixed set of
+// username and password pairs in constant time. The principal is the
+// username.
+func BasicAuthUsers(users map[string]string) BasicAuthValidator {
+	return func(c *Context, username, password string) (any, error) {
+		expected, ok := users[username]
+		if !SecureCompare(password, expected) || !ok {
+			return nil, ErrUnauthorized
+		}
+		return username, nil
+	}
+}
+
+// SecureCompare reports whether a and b are equal in time independent of
+// their content and length, for comparing secrets.
+func SecureCompare(a, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+func BearerAuth(fn BearerValidator) Middleware {
+	return BearerAuthWithConfig(BearerAuthConfig{Validator: fn})
+}
+
+// BearerAuthWithConfig authenticates RFC 6750 bearer tokens sent in the
+// Authorization header.
+func BearerAuthWithConfig(config BearerAuthConfig) Middleware {
+	if config.Validator == nil {
+		panic("teta: bearer auth requires a validator")
+	}
+	challenge := `Bearer realm=` + strconv.Quote(realmOr(config.Realm))
+
+	return authMiddleware(config.Skipper, func(c *Context) (any, string, error) {
+		token, ok := bearerToken(c.Request)
+		if !ok {
+			return nil, challenge, ErrUnauthorized
+		}
+
+		principal, err := config.Validator(c, token)
+		return principal, challenge + `, error="invalid_token"`, err
+	})
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get(HeaderAuthorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func APIKey(fn APIKeyValidator) Middleware {
+	config := DefaultAPIKeyConfig
+	config.Validator = fn
+	return APIKeyWithConfig(config)
+}
+
+func APIKeyWithConfig(config APIKeyConfig) Middleware {
+	if config.Validator == nil {
+		panic("teta: API key auth requires a validator")
+	}
+	if config.KeyLookup == "" {
+		config.KeyLookup = DefaultAPIKeyConfig.KeyLookup
+	}
+
+	lookups := parseValueLookup(config.KeyLookup)
+	challenge := `APIKey realm=` + strconv.Quote(realmOr(config.Realm))
+
+	return authMiddleware(config.Skipper, func(c *Context) (any, string, error) {
+		for _, lookup := range lookups {
+			if key := lookup(c); key != "" {
+				principal, err := config.Validator(c, key)
+				return principal, challenge, err
+			}
+		}
+		return nil, challenge, ErrUnauthorized
+	})
+}
+
+// authMiddleware runs authenticate and stores the principal. Validator errors
+// other than 401 HTTPErrors, such as a failing user store, are returned
+// unchanged.
+func authMiddleware(skipper Skipper, authenticate func(c *Context) (any, string, error)) Middleware {
+	if skipper == nil {
+		skipper = DefaultSkipper
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			if skipper(c) {
+				return next(c)
+			}
+
+			principal, challenge, err := authenticate(c)
+			if err != nil {
+				var httpErr *HTTPError
+				if errors.As(err, &httpErr) && httpErr.code == http.StatusUnauthorized {
+					c.Writer.Header().Set(HeaderWWWAuthenticate, challenge)
+				}
+				return err
+			}
+
+			PrincipalKey.Set(c, principal)
+			switch p := principal.(type) {
+			case string:
+				UserKey.Set(c, p)
+			case fmt.Stringer:
+				UserKey.Set(c, p.String())
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func realmOr(realm string) string {
+	if realm == "" {
+		return defaultAuthRealm
+	}
+	return realm
+}
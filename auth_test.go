@@ -0,0 +1,103 @@
+package teta
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testPrincipal struct{ name string }
+
+func (p testPrincipal) String() string { return p.name }
+
+func TestAuthMiddleware(t *testing.T) {
+	basic := BasicAuthUsers(map[string]string{"joe": "secret"})
+	bearer := func(c *Context, token string) (any, error) {
+		switch token {
+		case "good":
+			return testPrincipal{"svc"}, nil
+		case "down":
+			return nil, NewHTTPError(http.StatusServiceUnavailable, "token store unavailable")
+		}
+		return nil, ErrUnauthorized
+	}
+	apiKey := func(c *Context, key string) (any, error) {
+		if key != "k-123" {
+			return nil, ErrUnauthorized
+		}
+		return "client", nil
+	}
+
+	tests := []struct {
+		name      string
+		mw        Middleware
+		header    string
+		value     string
+		code      int
+		challenge string
+		user      string
+	}{
+		{"basic", BasicAuth(basic), HeaderAuthorization, "Basic am9lOnNlY3JldA==", 200, "", "joe"},
+		{"basic wrong password", BasicAuth(basic), HeaderAuthorization, "Basic am9lOndyb25n", 401, `Basic realm="Restricted", charset="UTF-8"`, ""},
+		{"basic missing", BasicAuth(basic), "", "", 401, `Basic realm="Restricted", charset="UTF-8"`, ""},
+		{"bearer", BearerAuth(bearer), HeaderAuthorization, "Bearer good", 200, "", "svc"},
+		{"bearer invalid", BearerAuth(bearer), HeaderAuthorization, "Bearer bad", 401, `Bearer realm="Restricted", error="invalid_token"`, ""},
+		{"bearer missing", BearerAuth(bearer), "", "", 401, `Bearer realm="Restricted"`, ""},
+		{"bearer store error", BearerAuth(bearer), HeaderAuthorization, "Bearer down", 503, "", ""},
+		{"api key", APIKey(apiKey), "X-API-Key", "k-123", 200, "", "client"},
+		{"api key invalid", APIKey(apiKey), "X-API-Key", "nope", 401, `APIKey realm="Restricted"`, ""},
+		{"api key realm", APIKeyWithConfig(APIKeyConfig{Validator: apiKey, Realm: "api"}), "", "", 401, `APIKey realm="api"`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New()
+			r.SetLogger(newLogger(io.Discard))
+			r.Use(tt.mw)
+
+			var user string
+			var principal any
+			r.Get("/", func(c *Context) error {
+				user, _ = UserKey.Get(c)
+				principal, _ = PrincipalKey.Get(c)
+				return c.NoContent(http.StatusOK)
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.code {
+				t.Fatalf("status = %d, want %d", w.Code, tt.code)
+			}
+			if got := w.Header().Get(HeaderWWWAuthenticate); got != tt.challenge {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, tt.challenge)
+			}
+			if user != tt.user {
+				t.Errorf("UserKey = %q, want %q", user, tt.user)
+			}
+			if tt.code == http.StatusOK && principal == nil {
+				t.Error("PrincipalKey was not set")
+			}
+		})
+	}
+}
+
+func TestAuthSkipper(t *testing.T) {
+	r := New()
+	r.Use(BasicAuthWithConfig(BasicAuthConfig{
+		Validator: BasicAuthUsers(map[string]string{"joe": "secret"}),
+		Skipper:   func(c *Context) bool { return c.Request.URL.Path == "/health" },
+	}))
+	r.Get("/health", func(c *Context) error { return c.NoContent(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+}
@@ -1,7 +1,6 @@
 package teta
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"reflect"
@@ -10,7 +9,8 @@ import (
 )
 
 type defaultBinder struct {
-	r *http.Request
+	r          *http.Request
+	serializer JSONSerializer
 }
 
 // type Binder interface {
@@ -113,7 +113,7 @@ func (b *defaultBinder) bindBody(r *http.Request, dest any) error {
 
 	switch {
 	case strings.Contains(contentType, "application/json"):
-		if err := json.NewDecoder(r.Body).Decode(&dest); err != nil {
+		if err := b.serializer.Deserialize(r.Body, dest); err != nil {
 			return fmt.Errorf("json decode failed: %w", err)
 		}
 
@@ -0,0 +1,365 @@
+package teta
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Compressor is a streaming encoder. Compressors are pooled: Reset points
+// a used one at a new response.
+type Compressor interface {
+	io.Writer
+	Reset(w io.Writer)
+	Flush() error
+	Close() error
+}
+
+type CompressionEncoding struct {
+	// Encoding is the Content-Encoding token, e.g. "gzip".
+	Encoding string
+	New      func(w io.Writer) (Compressor, error)
+}
+
+func GzipEncoding(level int) CompressionEncoding {
+	return CompressionEncoding{
+		Encoding: "gzip",
+		New: func(w io.Writer) (Compressor, error) {
+			return gzip.NewWriterLevel(w, level)
+		},
+	}
+}
+
+// DeflateEncoding is the HTTP "deflate" coding, which is the zlib format
+// (RFC 1950) rather than a raw deflate stream.
+func DeflateEncoding(level int) CompressionEncoding {
+	return CompressionEncoding{
+		Encoding: "deflate",
+		New: func(w io.Writer) (Compressor, error) {
+			return zlib.NewWriterLevel(w, level)
+		},
+	}
+}
+
+type CompressConfig struct {
+	Skipper Skipper
+	// Encodings are offered in order of preference when the client accepts
+	// several with the same quality.
+	Encodings []CompressionEncoding
+	// MinLength is the body size below which responses are sent as is.
+	// Flushed responses are compressed regardless.
+	MinLength int
+	// ExcludedContentTypes are media types already compressed. Entries
+	// ending in "/" match a whole top-level type.
+	ExcludedContentTypes []string
+}
+
+var ErrEncodingNotAcceptable = NewHTTPError(http.StatusNotAcceptable, "no acceptable content encoding")
+
+var DefaultCompressConfig = CompressConfig{
+	Skipper: DefaultSkipper,
+	Encodings: []CompressionEncoding{
+		GzipEncoding(gzip.DefaultCompression),
+		DeflateEncoding(flate.DefaultCompression),
+	},
+	MinLength: 1024,
+	ExcludedContentTypes: []string{
+		"image/png", "image/jpeg", "image/gif", "image/webp", "image/avif",
+		"video/", "audio/",
+		"font/woff", "font/woff2",
+		"application/zip", "application/gzip", "application/x-gzip",
+		"application/zstd", "application/x-bzip2", "application/x-xz",
+		"application/x-7z-compressed", "application/x-rar-compressed",
+		"application/pdf",
+	},
+}
+
+func Compress() Middleware {
+	return CompressWithConfig(DefaultCompressConfig)
+}
+
+// CompressWithConfig compresses response bodies with the encoding the client
+// prefers in Accept-Encoding. The body is buffered up to MinLength to decide,
+// then streamed through a pooled Compressor. Error responses written after
+// the handler returns are not compressed.
+func CompressWithConfig(config CompressConfig) Middleware {
+	if config.Skipper == nil {
+		config.Skipper = DefaultCompressConfig.Skipper
+	}
+	if len(config.Encodings) == 0 {
+		config.Encodings = DefaultCompressConfig.Encodings
+	}
+	if config.MinLength == 0 {
+		config.MinLength = DefaultCompressConfig.MinLength
+	}
+	if config.ExcludedContentTypes == nil {
+		config.ExcludedContentTypes = DefaultCompressConfig.ExcludedContentTypes
+	}
+
+	pools := make([]sync.Pool, len(config.Encodings))
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			c.Writer.Header().Add(HeaderVary, HeaderAcceptEncoding)
+			if c.Request.Method == http.MethodHead {
+				return next(c)
+			}
+
+			i, ok := negotiateEncoding(c.Request.Header.Get(HeaderAcceptEncoding), config.Encodings)
+			if !ok {
+				return ErrEncodingNotAcceptable
+			}
+			if i < 0 {
+				return next(c)
+			}
+
+			res := c.Response()
+			cw := &compressWriter{
+				ResponseWriter: res.Writer,
+				response:       res,
+				encoding:       &config.Encodings[i],
+				pool:           &pools[i],
+				config:         &config,
+			}
+			res.Writer = cw
+			defer func() {
+				cw.close()
+				res.Writer = cw.ResponseWriter
+			}()
+
+			return next(c)
+		}
+	}
+}
+
+// negotiateEncoding returns the index of the acceptable encoding with the
+// highest quality, or -1 to send the identity encoding. It reports false
+// when the client refuses identity, with "identity;q=0" or "*;q=0", and
+// accepts none of the encodings either.
+func negotiateEncoding(header string, encodings []CompressionEncoding) (int, bool) {
+	if header == "" {
+		return -1, true
+	}
+	specs := parseQualityValues(header)
+
+	best, bestQ := -1, 0.0
+	for i, e := range encodings {
+		if q := encodingQuality(specs, strings.ToLower(e.Encoding), 0); q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+
+	identity := encodingQuality(specs, "identity", 1)
+	if best < 0 || identity > bestQ {
+		return -1, identity > 0
+	}
+	return best, true
+}
+
+// encodingQuality returns the quality of coding in specs, falling back to
+// the "*" entry and then to def when the coding is not listed.
+func encodingQuality(specs []acceptSpec, coding string, def float64) float64 {
+	q, wildcard := -1.0, -1.0
+	for _, spec := range specs {
+		switch spec.value {
+		case coding:
+			q = spec.q
+		case "*":
+			wildcard = spec.q
+		}
+	}
+
+	switch {
+	case q >= 0:
+		return q
+	case wildcard >= 0:
+		return wildcard
+	}
+	return def
+}
+
+// compressWriter sits between Response and the connection. It holds back
+// the status and the first MinLength bytes until it knows whether the
+// response is worth compressing.
+type compressWriter struct {
+	http.ResponseWriter
+	response *Response
+	encoding *CompressionEncoding
+	pool     *sync.Pool
+	config   *CompressConfig
+
+	status     int
+	buf        *bytes.Buffer
+	decided    bool
+	compressor Compressor
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if code < http.StatusOK {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if !w.decided {
+		if w.buf == nil {
+			w.buf = getBuffer()
+		}
+		w.buf.Write(b)
+		if w.buf.Len() < w.config.MinLength {
+			return len(b), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if w.compressor != nil {
+		return w.compressor.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) Flush() {
+	w.FlushError()
+}
+
+// FlushError sends what was written so far, compressing streams such as
+// server-sent events as they go.
+func (w *compressWriter) FlushError() error {
+	if !w.decided {
+		if err := w.decide(true); err != nil {
+			return err
+		}
+	}
+	if w.compressor != nil {
+		if err := w.compressor.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide sends the headers, with compression when allowed and worthwhile,
+// followed by the buffered body.
+func (w *compressWriter) decide(allowed bool) error {
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	var body []byte
+	if w.buf != nil {
+		body = w.buf.Bytes()
+	}
+
+	h := w.Header()
+	if allowed && w.compressible(h, body) {
+		compressor, err := w.acquire()
+		if err != nil {
+			return err
+		}
+		w.compressor = compressor
+
+		h.Del(HeaderContentLength)
+		h.Set(HeaderContentEncoding, w.encoding.Encoding)
+		if etag := h.Get(HeaderETag); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set(HeaderETag, "W/"+etag)
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	var err error
+	if len(body) > 0 {
+		if w.compressor != nil {
+			_, err = w.compressor.Write(body)
+		} else {
+			_, err = w.ResponseWriter.Write(body)
+		}
+	}
+	if w.buf != nil {
+		putBuffer(w.buf)
+		w.buf = nil
+	}
+	return err
+}
+
+func (w *compressWriter) compressible(h http.Header, body []byte) bool {
+	switch {
+	case w.status == http.StatusNoContent,
+		w.status == http.StatusPartialContent,
+		w.status == http.StatusNotModified:
+		return false
+	case h.Get(HeaderContentEncoding) != "":
+		return false
+	}
+
+	contentType := h.Get(HeaderContentType)
+	if contentType == "" && len(body) > 0 {
+		contentType = http.DetectContentType(body)
+		h.Set(HeaderContentType, contentType)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, excluded := range w.config.ExcludedContentTypes {
+		if mediaType == excluded || strings.HasSuffix(excluded, "/") && strings.HasPrefix(mediaType, excluded) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *compressWriter) acquire() (Compressor, error) {
+	if compressor, ok := w.pool.Get().(Compressor); ok {
+		compressor.Reset(w.ResponseWriter)
+		return compressor, nil
+	}
+	return w.encoding.New(w.ResponseWriter)
+}
+
+// close finishes the response once the handler returns. Bodies that stayed
+// under MinLength go out uncompressed.
+func (w *compressWriter) close() {
+	if w.response.Hijacked() {
+		return
+	}
+
+	if !w.decided && (w.status != 0 || w.buf != nil) {
+		w.decide(false)
+	}
+	if w.buf != nil {
+		putBuffer(w.buf)
+		w.buf = nil
+	}
+
+	if w.compressor != nil {
+		w.compressor.Close()
+		w.compressor.Reset(io.Discard)
+		w.pool.Put(w.compressor)
+		w.compressor = nil
+	}
+}
@@ -0,0 +1,89 @@
+package teta
+
+import (
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	encodings := DefaultCompressConfig.Encodings
+
+	tests := []struct {
+		header string
+		want   string
+		ok     bool
+	}{
+		{"", "", true},
+		{"gzip, deflate", "gzip", true},
+		{"gzip;q=0.5, deflate", "deflate", true},
+		{"gzip;q=0, *", "deflate", true},
+		{"gzip;q=0.5, identity", "", true},
+		{"br", "", true},
+		{"br, identity;q=0", "", false},
+		{"*;q=0", "", false},
+		{"*;q=0, identity", "", true},
+		{"*;q=0, gzip", "gzip", true},
+	}
+	for _, tt := range tests {
+		i, ok := negotiateEncoding(tt.header, encodings)
+		got := ""
+		if i >= 0 {
+			got = encodings[i].Encoding
+		}
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("negotiateEncoding(%q) = %q, %v; want %q, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCompressDeflateIsZlib(t *testing.T) {
+	body := strings.Repeat("teta ", 500)
+
+	r := New()
+	r.Use(Compress())
+	r.Get("/", func(c *Context) error {
+		return c.String(http.StatusOK, body)
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(HeaderAcceptEncoding, "deflate")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if got := w.Header().Get(HeaderContentEncoding); got != "deflate" {
+		t.Fatalf("Content-Encoding = %q, want deflate", got)
+	}
+	zr, err := zlib.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decoded) != body {
+		t.Fatal("decoded body does not match")
+	}
+}
+
+func TestCompressNotAcceptable(t *testing.T) {
+	r := New()
+	r.SetLogger(newLogger(io.Discard))
+	r.Use(Compress())
+	r.Get("/", func(c *Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(HeaderAcceptEncoding, "br, identity;q=0")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotAcceptable {
+		t.Fatalf("status = %d, want 406", w.Code)
+	}
+}
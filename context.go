@@ -2,8 +2,10 @@ package teta
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"regexp"
 	"strconv"
 	"sync"
 	"time"
@@ -13,8 +15,15 @@ type Context struct {
 	Writer    http.ResponseWriter
 	Request   *http.Request
 	Validator Validator
-	ctx       context.Context
+	store     *contextStore
 	binder    defaultBinder
+	router    *Router
+	response  Response
+	log       requestLogger
+	// errHandled is set once Error rendered the error the handler chain
+	// returns, so the router does not render it again.
+	errHandled bool
+	stream     *EventStream
 }
 
 var ctxPool = sync.Pool{
@@ -30,24 +39,84 @@ func NewContext(w http.ResponseWriter, r *http.Request, v Validator) *Context {
 }
 
 func (c *Context) reset(w http.ResponseWriter, r *http.Request, v Validator) {
-	c.Writer = w
+	c.response.reset(w)
+	c.Writer = &c.response
 	c.Request = r
 	c.Validator = v
-	c.ctx = r.Context()
+	c.store = nil
+	c.errHandled = false
+	c.stream = nil
 	c.binder.r = r
+	c.binder.serializer = defaultJSONSerializer
 }
 
 func (c *Context) Release() {
+	if c.stream != nil {
+		c.stream.Close()
+		c.stream = nil
+	}
+
 	c.Writer = nil
 	c.Request = nil
 	c.Validator = nil
-	c.ctx = context.Background()
+	c.store = nil
 	c.binder.r = nil
+	c.binder.serializer = nil
+	c.router = nil
+	c.response.reset(nil)
+	c.log = requestLogger{}
+	c.errHandled = false
 	ctxPool.Put(c)
 }
 
+// Response returns the writer wrapper installed for the request. It stays the
+// innermost writer even when middleware replaces c.Writer.
+func (c *Context) Response() *Response {
+	return &c.response
+}
+
+// Error renders err with the router's HTTPErrorHandler right away. It is
+// meant for middleware that needs the final response, such as AccessLog.
+// The error may still be returned up the chain: the router then skips the
+// handler, so it runs once per request.
+func (c *Context) Error(err error) {
+	handler := defaultHTTPErrorHandler
+	if c.router != nil && c.router.httpErrorHandler != nil {
+		handler = c.router.httpErrorHandler
+	}
+	handler(err, c)
+	c.errHandled = true
+}
+
 func (c *Context) Validate(s any) error {
-	return c.Validator.StructCtx(c.Request.Context(), s)
+	return c.Validator.StructCtx(c.Context(), s)
+}
+
+// Context returns the request context. It carries the values set with Set
+// and Key.Set as well as anything added by std middleware.
+func (c *Context) Context() context.Context {
+	return c.Request.Context()
+}
+
+// SetContext replaces the request context, for example with one carrying a
+// tracing span. Values set on the Context stay visible through it.
+func (c *Context) SetContext(ctx context.Context) {
+	if c.store != nil {
+		ctx = c.store.wrap(ctx)
+	}
+	c.Request = c.Request.WithContext(ctx)
+}
+
+// adoptRequest installs a request handed over by std middleware, keeping the
+// Context values reachable when its context does not derive from them.
+func (c *Context) adoptRequest(r *http.Request) {
+	if c.store != nil {
+		ctx := r.Context()
+		if wrapped := c.store.wrap(ctx); wrapped != ctx {
+			r = r.WithContext(wrapped)
+		}
+	}
+	c.Request = r
 }
 
 func (c *Context) writeContentType(value string) {
@@ -65,25 +134,195 @@ func (c *Context) String(status int, str string) error {
 	return err
 }
 
+const defaultJSONIndent = "  "
+
+// JSON writes v as JSON. Output is indented when the router is in debug mode
+// or the request carries a "pretty" query parameter.
 func (c *Context) JSON(status int, v any) error {
-	c.writeContentType(MIMEApplicationJSON)
+	indent := ""
+	if c.prettyJSON() {
+		indent = defaultJSONIndent
+	}
+	return c.JSONPretty(status, v, indent)
+}
+
+func (c *Context) JSONPretty(status int, v any, indent string) error {
+	return c.encode(status, MIMEApplicationJSON, serializerEncoder{c.JSONSerializer(), indent}, v)
+}
+
+func (c *Context) prettyJSON() bool {
+	if c.router != nil && c.router.debug {
+		return true
+	}
+	return c.Request.URL.Query().Has("pretty")
+}
+
+var jsonpCallbackRegexp = regexp.MustCompile(`^[a-zA-Z_$][0-9a-zA-Z_$]*(\.[a-zA-Z_$][0-9a-zA-Z_$]*)*$`)
+
+// JSONP writes v wrapped in a call to callback. The callback name usually
+// comes from the query string, so anything that is not a plain JavaScript
+// identifier path is rejected with a 400 HTTPError.
+func (c *Context) JSONP(status int, callback string, v any) error {
+	if len(callback) > 128 || !jsonpCallbackRegexp.MatchString(callback) {
+		return NewHTTPError(http.StatusBadRequest, "invalid jsonp callback")
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	buf.WriteString("/**/ typeof ")
+	buf.WriteString(callback)
+	buf.WriteString(" === 'function' && ")
+	buf.WriteString(callback)
+	buf.WriteString("(")
+	if err := c.JSONSerializer().Serialize(buf, v, ""); err != nil {
+		return err
+	}
+	buf.WriteString(");")
+
+	c.Writer.Header().Set(HeaderXContentTypeOptions, "nosniff")
+	return c.Blob(status, MIMEApplicationJavaScriptCharsetUTF8, buf.Bytes())
+}
+
+func (c *Context) XML(status int, v any) error {
+	return c.encode(status, MIMEApplicationXMLCharsetUTF8, XMLEncoder, v)
+}
+
+// encode renders v into a pooled buffer first, so encoding errors can still
+// be turned into an error response and Content-Length is always exact.
+func (c *Context) encode(status int, contentType string, enc Encoder, v any) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if err := enc.Encode(buf, v); err != nil {
+		return err
+	}
+
+	return c.Blob(status, contentType, buf.Bytes())
+}
+
+func (c *Context) Blob(status int, contentType string, data []byte) error {
+	c.writeContentType(contentType)
+	c.Writer.Header().Set(HeaderContentLength, strconv.Itoa(len(data)))
+	c.Writer.WriteHeader(status)
+
+	_, err := c.Writer.Write(data)
+	return err
+}
+
+func (c *Context) Stream(status int, contentType string, r io.Reader) error {
+	c.writeContentType(contentType)
 	c.Writer.WriteHeader(status)
 
-	return json.NewEncoder(c.Writer).Encode(&v)
+	_, err := io.Copy(c.Writer, r)
+	return err
+}
+
+func (c *Context) NoContent(status int) error {
+	c.Writer.WriteHeader(status)
+	return nil
+}
+
+var ErrInvalidRedirectCode = errors.New("invalid redirect status code")
+
+func (c *Context) Redirect(status int, url string) error {
+	if status < http.StatusMultipleChoices || status > http.StatusPermanentRedirect {
+		return ErrInvalidRedirectCode
+	}
+
+	c.Writer.Header().Set(HeaderLocation, url)
+	c.Writer.WriteHeader(status)
+	return nil
+}
+
+var defaultEncoders = NewEncoderRegistry()
+
+func (c *Context) setRouter(router *Router) {
+	c.router = router
+	c.binder.serializer = c.JSONSerializer()
+}
+
+func (c *Context) JSONSerializer() JSONSerializer {
+	if c.router != nil && c.router.jsonSerializer != nil {
+		return c.router.jsonSerializer
+	}
+	return defaultJSONSerializer
+}
+
+func (c *Context) encoders() *EncoderRegistry {
+	if c.router != nil && c.router.encoders != nil {
+		return c.router.encoders
+	}
+	return defaultEncoders
 }
 
+// Negotiate encodes data with the registered encoder that best matches the
+// request Accept header, or returns a 406 HTTPError when none is acceptable.
+// JSON is indented as it is by Context.JSON.
+func (c *Context) Negotiate(status int, data any) error {
+	c.Writer.Header().Add(HeaderVary, HeaderAccept)
+
+	enc, contentType, ok := c.encoders().Lookup(c.Request.Header.Get(HeaderAccept))
+	if !ok {
+		return NewHTTPError(http.StatusNotAcceptable, http.StatusText(http.StatusNotAcceptable))
+	}
+	if se, ok := enc.(serializerEncoder); ok && se.indent == "" && c.prettyJSON() {
+		se.indent = defaultJSONIndent
+		enc = se
+	}
+
+	return c.encode(status, contentType, enc, data)
+}
+
+// ContextKey is the request context key of a value stored with Set.
 type ContextKey struct{ key string }
 
+// NewContextKey returns the key under which Set stores name, so plain
+// net/http code can read Context values and add its own for Get.
+func NewContextKey(name string) ContextKey {
+	return ContextKey{name}
+}
+
+// Set stores a request-scoped value. Values are kept in a map owned by the
+// Context and are also visible through c.Request.Context().Value with a
+// ContextKey, so libraries that only see the request can read them.
 func (c *Context) Set(key string, value any) {
-	c.ctx = context.WithValue(c.ctx, ContextKey{key}, value)
+	c.valueStore().set(key, value)
 }
 
+// Get returns a value set with Set, or added under a ContextKey to the
+// request context by std middleware.
 func (c *Context) Get(key string) any {
-	return c.ctx.Value(ContextKey{key})
+	if c.store != nil {
+		if v, ok := c.store.get(key); ok {
+			return v
+		}
+	}
+	return c.Request.Context().Value(ContextKey{key})
+}
+
+// valueStore returns the store of the request and makes sure the request
+// context sees it. A context installed directly on c.Request, e.g. by
+// tracing middleware, becomes the parent of the store instead of being
+// dropped.
+func (c *Context) valueStore() *contextStore {
+	ctx := c.Request.Context()
+	if c.store == nil {
+		c.store = newContextStore(ctx)
+		c.Request = c.Request.WithContext(c.store)
+		return c.store
+	}
+
+	if ctx != context.Context(c.store) {
+		if wrapped := c.store.wrap(ctx); wrapped != ctx {
+			c.Request = c.Request.WithContext(wrapped)
+		}
+	}
+	return c.store
 }
 
 func (c *Context) GetString(key string) string {
-	if val := c.ctx.Value(ContextKey{key}); val != nil {
+	if val := c.Get(key); val != nil {
 		if s, ok := val.(string); ok {
 			return s
 		}
@@ -92,7 +331,7 @@ func (c *Context) GetString(key string) string {
 }
 
 func (c *Context) GetInt(key string) int {
-	if val := c.ctx.Value(ContextKey{key}); val != nil {
+	if val := c.Get(key); val != nil {
 		switch v := val.(type) {
 		case int:
 			return v
@@ -108,7 +347,7 @@ func (c *Context) GetInt(key string) int {
 }
 
 func (c *Context) GetBool(key string) bool {
-	if val := c.ctx.Value(ContextKey{key}); val != nil {
+	if val := c.Get(key); val != nil {
 		if b, ok := val.(bool); ok {
 			return b
 		}
@@ -122,7 +361,7 @@ func (c *Context) GetBool(key string) bool {
 }
 
 func (c *Context) GetFloat(key string) float64 {
-	if val := c.ctx.Value(ContextKey{key}); val != nil {
+	if val := c.Get(key); val != nil {
 		switch v := val.(type) {
 		case float64:
 			return v
@@ -138,7 +377,7 @@ func (c *Context) GetFloat(key string) float64 {
 }
 
 func (c *Context) GetStringSlice(key string) []string {
-	if val := c.ctx.Value(ContextKey{key}); val != nil {
+	if val := c.Get(key); val != nil {
 		if slice, ok := val.([]string); ok {
 			return slice
 		}
@@ -156,7 +395,7 @@ func (c *Context) GetStringSlice(key string) []string {
 }
 
 func (c *Context) GetMap(key string) map[string]any {
-	if val := c.ctx.Value(ContextKey{key}); val != nil {
+	if val := c.Get(key); val != nil {
 		if m, ok := val.(map[string]any); ok {
 			return m
 		}
@@ -165,7 +404,7 @@ func (c *Context) GetMap(key string) map[string]any {
 }
 
 func (c *Context) GetTime(key string) time.Time {
-	if val := c.ctx.Value(ContextKey{key}); val != nil {
+	if val := c.Get(key); val != nil {
 		switch v := val.(type) {
 		case time.Time:
 			return v
@@ -179,7 +418,7 @@ func (c *Context) GetTime(key string) time.Time {
 }
 
 func (c *Context) GetDuration(key string) time.Duration {
-	if val := c.ctx.Value(ContextKey{key}); val != nil {
+	if val := c.Get(key); val != nil {
 		switch v := val.(type) {
 		case time.Duration:
 			return v
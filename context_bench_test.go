@@ -0,0 +1,69 @@
+package teta
+
+import (
+	"context"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+var benchKeys = func() []string {
+	keys := make([]string, 10)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	return keys
+}()
+
+func BenchmarkContextSetGet(b *testing.B) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		c := NewContext(w, r, nil)
+		for _, key := range benchKeys {
+			c.Set(key, key)
+		}
+		for _, key := range benchKeys {
+			_ = c.GetString(key)
+		}
+		c.Release()
+	}
+}
+
+func BenchmarkContextRequestValue(b *testing.B) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		c := NewContext(w, r, nil)
+		for _, key := range benchKeys {
+			c.Set(key, key)
+		}
+		ctx := c.Request.Context()
+		for _, key := range benchKeys {
+			_ = ctx.Value(ContextKey{key})
+		}
+		c.Release()
+	}
+}
+
+func BenchmarkContextWithValueChain(b *testing.B) {
+	r := httptest.NewRequest("GET", "/", nil)
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		ctx := r.Context()
+		for _, key := range benchKeys {
+			ctx = context.WithValue(ctx, ContextKey{key}, key)
+		}
+		for _, key := range benchKeys {
+			_ = ctx.Value(ContextKey{key})
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package teta
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestContextValuesDoNotLeakAcrossRequests(t *testing.T) {
+	r := New()
+
+	var kept context.Context
+	r.Get("/a", func(c *Context) error {
+		c.Set("user", "alice")
+		kept = c.Request.Context()
+		return nil
+	})
+	r.Get("/b", func(c *Context) error {
+		c.Set("user", "bob")
+		return nil
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/a", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/b", nil))
+
+	if got := kept.Value(ContextKey{"user"}); got != "alice" {
+		t.Fatalf("kept context user = %v, want alice", got)
+	}
+}
+
+func TestContextSetWhileValueIsRead(t *testing.T) {
+	c := NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), nil)
+	defer c.Release()
+
+	c.Set("a", 1)
+	ctx := c.Request.Context()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 1000 {
+			ctx.Value(ContextKey{"a"})
+		}
+	}()
+	for i := range 1000 {
+		c.Set("b", i)
+	}
+	wg.Wait()
+}
+
+type spanKey struct{}
+
+func TestContextSetKeepsContextInstalledOnRequest(t *testing.T) {
+	c := NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), nil)
+	defer c.Release()
+
+	c.Set("early", 1)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), spanKey{}, "span"))
+	c.Set("tenant", "acme")
+
+	ctx := c.Request.Context()
+	if ctx.Value(spanKey{}) != "span" {
+		t.Fatal("span lost after Set")
+	}
+	if ctx.Value(NewContextKey("tenant")) != "acme" || ctx.Value(NewContextKey("early")) != 1 {
+		t.Fatal("values not visible through the request context")
+	}
+}
+
+func TestContextGetSeesStdMiddlewareValues(t *testing.T) {
+	r := New()
+	r.Use(FromStdMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctx := context.WithValue(req.Context(), NewContextKey("std"), "yes")
+			next.ServeHTTP(w, req.WithContext(ctx))
+		})
+	}))
+
+	var got any
+	r.Get("/", func(c *Context) error {
+		got = c.Get("std")
+		return nil
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if got != "yes" {
+		t.Fatalf("Get(std) = %v, want yes", got)
+	}
+}
+
+func TestContextErrorRunsHandlerOnce(t *testing.T) {
+	r := New()
+	r.SetLogger(newLogger(io.Discard))
+
+	calls := 0
+	r.SetCustomHTTPErrorHandler(func(err error, c *Context) {
+		calls++
+		c.String(http.StatusTeapot, err.Error())
+	})
+	r.Use(AccessLog())
+	r.Get("/", func(c *Context) error {
+		return errors.New("boom")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if calls != 1 {
+		t.Fatalf("error handler ran %d times, want 1", calls)
+	}
+	if w.Code != http.StatusTeapot {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusTeapot)
+	}
+}
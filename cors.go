@@ -0,0 +1,181 @@
+package teta
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type CORSConfig struct {
+	Skipper Skipper
+	// AllowOrigins lists exact origins, "*" for any origin, or wildcard
+	// subdomains such as "https://*.example.com".
+	AllowOrigins []string
+	// AllowOriginFunc is consulted for origins AllowOrigins does not match.
+	AllowOriginFunc func(origin string) bool
+	AllowMethods    []string
+	// AllowHeaders answers preflight requests. When empty, the headers the
+	// browser asks for are allowed.
+	AllowHeaders     []string
+	AllowCredentials bool
+	ExposeHeaders    []string
+	// MaxAge is how long, in seconds, browsers may cache a preflight
+	// response. Zero omits the header, a negative value disables caching.
+	MaxAge int
+}
+
+var DefaultCORSConfig = CORSConfig{
+	Skipper:      DefaultSkipper,
+	AllowOrigins: []string{"*"},
+	AllowMethods: []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodPost,
+		http.MethodDelete,
+	},
+}
+
+func CORS() Middleware {
+	return CORSWithConfig(DefaultCORSConfig)
+}
+
+// CORSWithConfig answers preflight requests itself, since every route also
+// answers OPTIONS, and adds the response headers to actual requests from
+// allowed origins. It panics when "*" is combined with AllowCredentials,
+// which browsers reject; list the origins or use AllowOriginFunc instead.
+func CORSWithConfig(config CORSConfig) Middleware {
+	if config.Skipper == nil {
+		config.Skipper = DefaultCORSConfig.Skipper
+	}
+	if len(config.AllowOrigins) == 0 && config.AllowOriginFunc == nil {
+		config.AllowOrigins = DefaultCORSConfig.AllowOrigins
+	}
+	if len(config.AllowMethods) == 0 {
+		config.AllowMethods = DefaultCORSConfig.AllowMethods
+	}
+
+	origins := newOriginMatcher(config.AllowOrigins)
+	if origins.any && config.AllowCredentials {
+		panic("teta: CORS cannot allow any origin with credentials")
+	}
+
+	allowMethods := strings.Join(config.AllowMethods, ", ")
+	allowHeaders := strings.Join(config.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(config.ExposeHeaders, ", ")
+
+	maxAge := ""
+	if config.MaxAge > 0 {
+		maxAge = strconv.Itoa(config.MaxAge)
+	} else if config.MaxAge < 0 {
+		maxAge = "0"
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			r := c.Request
+			h := c.Writer.Header()
+			origin := r.Header.Get(HeaderOrigin)
+			preflight := r.Method == http.MethodOptions && r.Header.Get(HeaderAccessControlRequestMethod) != ""
+
+			h.Add(HeaderVary, HeaderOrigin)
+			if preflight {
+				h.Add(HeaderVary, HeaderAccessControlRequestMethod)
+				h.Add(HeaderVary, HeaderAccessControlRequestHeaders)
+			}
+
+			if origin == "" {
+				return next(c)
+			}
+
+			allowed := origins.match(origin) || config.AllowOriginFunc != nil && config.AllowOriginFunc(origin)
+			if !allowed {
+				if preflight {
+					return c.NoContent(http.StatusNoContent)
+				}
+				return next(c)
+			}
+
+			if origins.any {
+				h.Set(HeaderAccessControlAllowOrigin, "*")
+			} else {
+				h.Set(HeaderAccessControlAllowOrigin, origin)
+			}
+			if config.AllowCredentials {
+				h.Set(HeaderAccessControlAllowCredentials, "true")
+			}
+
+			if !preflight {
+				if exposeHeaders != "" {
+					h.Set(HeaderAccessControlExposeHeaders, exposeHeaders)
+				}
+				return next(c)
+			}
+
+			h.Set(HeaderAccessControlAllowMethods, allowMethods)
+			if allowHeaders != "" {
+				h.Set(HeaderAccessControlAllowHeaders, allowHeaders)
+			} else if requested := r.Header.Get(HeaderAccessControlRequestHeaders); requested != "" {
+				h.Set(HeaderAccessControlAllowHeaders, requested)
+			}
+			if maxAge != "" {
+				h.Set(HeaderAccessControlMaxAge, maxAge)
+			}
+
+			return c.NoContent(http.StatusNoContent)
+		}
+	}
+}
+
+type originMatcher struct {
+	any       bool
+	exact     map[string]struct{}
+	wildcards [][2]string
+}
+
+func newOriginMatcher(origins []string) originMatcher {
+	m := originMatcher{exact: make(map[string]struct{}, len(origins))}
+
+	for _, o := range origins {
+		o = strings.ToLower(strings.TrimSuffix(o, "/"))
+		switch {
+		case o == "*":
+			m.any = true
+		case strings.Contains(o, "://*."):
+			prefix, suffix, _ := strings.Cut(o, "*")
+			m.wildcards = append(m.wildcards, [2]string{prefix, suffix})
+		default:
+			m.exact[o] = struct{}{}
+		}
+	}
+	return m
+}
+
+// match compares origins case-insensitively. A wildcard matches one or more
+// subdomain labels, but never the bare domain.
+func (m originMatcher) match(origin string) bool {
+	if m.any {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	if _, ok := m.exact[origin]; ok {
+		return true
+	}
+
+	for _, w := range m.wildcards {
+		if len(origin) <= len(w[0])+len(w[1]) || !strings.HasPrefix(origin, w[0]) || !strings.HasSuffix(origin, w[1]) {
+			continue
+		}
+		sub := origin[len(w[0]) : len(origin)-len(w[1])]
+		if !strings.ContainsAny(sub, "/:@") && !strings.HasPrefix(sub, ".") {
+			return true
+		}
+	}
+	return false
+}
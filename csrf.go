@@ -0,0 +1,300 @@
+package teta
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type CSRFConfig struct {
+	Skipper Skipper
+	// TokenLength is the number of random bytes in a token.
+	TokenLength int
+	// TokenLookup lists where the client sends the token, as comma separated
+	// "header:<name>", "form:<field>" or "query:<param>" sources.
+	TokenLookup string
+	// Store switches from double-submit cookies to synchronizer tokens kept
+	// server side, keyed by SessionID.
+	Store     CSRFStore
+	SessionID func(c *Context) string
+	// TrustedOrigins are accepted in Origin and Referer besides the origin
+	// of the request itself. Wildcard subdomains are allowed as in CORS.
+	TrustedOrigins []string
+	// APITokenSchemes are Authorization schemes that skip the check, as
+	// browsers never send them on their own. Basic and Digest credentials
+	// are cached and resent by browsers, so they are never skipped.
+	// Defaults to Bearer.
+	APITokenSchemes []string
+	// APITokenHeaders, such as X-API-Key, also skip the check. Only list
+	// headers that browsers cannot attach to cross-site requests.
+	APITokenHeaders []string
+
+	CookieName     string
+	CookieDomain   string
+	CookiePath     string
+	CookieMaxAge   int
+	CookieSecure   bool
+	CookieHTTPOnly bool
+	CookieSameSite http.SameSite
+}
+
+// CSRFStore keeps synchronizer tokens per session.
+type CSRFStore interface {
+	Token(ctx context.Context, sessionID string) (string, error)
+	SetToken(ctx context.Context, sessionID, token string) error
+}
+
+var DefaultCSRFConfig = CSRFConfig{
+	Skipper:         DefaultSkipper,
+	TokenLength:     32,
+	TokenLookup:     "header:" + HeaderXCSRFToken + ",form:_csrf",
+	APITokenSchemes: []string{"Bearer"},
+	CookieName:      "_csrf",
+	CookiePath:      "/",
+	CookieMaxAge:    86400,
+	CookieSameSite:  http.SameSiteLaxMode,
+}
+
+var (
+	ErrCSRFTokenMissing = NewHTTPError(http.StatusForbidden, "missing CSRF token")
+	ErrCSRFTokenInvalid = NewHTTPError(http.StatusForbidden, "invalid CSRF token")
+	ErrCSRFOrigin       = NewHTTPError(http.StatusForbidden, "cross-origin request denied")
+)
+
+var csrfKey = NewKey[string]("csrf")
+
+func CSRF() Middleware {
+	return CSRFWithConfig(DefaultCSRFConfig)
+}
+
+// CSRFWithConfig protects unsafe methods. The token of the request is
+// available through Context.CSRFToken for forms and templates; clients send
+// it back through one of the TokenLookup sources.
+func CSRFWithConfig(config CSRFConfig) Middleware {
+	if config.Skipper == nil {
+		config.Skipper = DefaultCSRFConfig.Skipper
+	}
+	if config.TokenLength == 0 {
+		config.TokenLength = DefaultCSRFConfig.TokenLength
+	}
+	if config.TokenLookup == "" {
+		config.TokenLookup = DefaultCSRFConfig.TokenLookup
+	}
+	if config.APITokenSchemes == nil {
+		config.APITokenSchemes = DefaultCSRFConfig.APITokenSchemes
+	}
+	if config.CookieName == "" {
+		config.CookieName = DefaultCSRFConfig.CookieName
+	}
+	if config.CookiePath == "" {
+		config.CookiePath = DefaultCSRFConfig.CookiePath
+	}
+	if config.CookieMaxAge == 0 {
+		config.CookieMaxAge = DefaultCSRFConfig.CookieMaxAge
+	}
+	if config.CookieSameSite == 0 {
+		config.CookieSameSite = DefaultCSRFConfig.CookieSameSite
+	}
+	if config.Store != nil && config.SessionID == nil {
+		panic("teta: CSRF Store requires SessionID")
+	}
+
+	lookups := parseValueLookup(config.TokenLookup)
+	trusted := newOriginMatcher(config.TrustedOrigins)
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			token, err := csrfToken(c, &config)
+			if err != nil {
+				return err
+			}
+			csrfKey.Set(c, token)
+
+			switch c.Request.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				return next(c)
+			}
+			if csrfAPIRequest(c.Request, &config) {
+				return next(c)
+			}
+
+			if !csrfOriginAllowed(c, trusted) {
+				return ErrCSRFOrigin
+			}
+
+			sent := ""
+			for _, lookup := range lookups {
+				if sent = lookup(c); sent != "" {
+					break
+				}
+			}
+			if sent == "" {
+				return ErrCSRFTokenMissing
+			}
+			if token == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				return ErrCSRFTokenInvalid
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// csrfToken returns the token of the session or cookie, issuing a new one
+// when there is none yet.
+func csrfToken(c *Context, config *CSRFConfig) (string, error) {
+	if config.Store != nil {
+		sid := config.SessionID(c)
+		if sid == "" {
+			return "", nil
+		}
+
+		token, err := config.Store.Token(c.Context(), sid)
+		if err != nil {
+			return "", err
+		}
+		if token == "" {
+			token = newCSRFToken(config.TokenLength)
+			if err := config.Store.SetToken(c.Context(), sid, token); err != nil {
+				return "", err
+			}
+		}
+		return token, nil
+	}
+
+	c.Writer.Header().Add(HeaderVary, HeaderCookie)
+	if cookie, err := c.Request.Cookie(config.CookieName); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+
+	token := newCSRFToken(config.TokenLength)
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     config.CookieName,
+		Value:    token,
+		Domain:   config.CookieDomain,
+		Path:     config.CookiePath,
+		MaxAge:   config.CookieMaxAge,
+		Secure:   config.CookieSecure || c.Scheme() == "https",
+		HttpOnly: config.CookieHTTPOnly,
+		SameSite: config.CookieSameSite,
+	})
+	return token, nil
+}
+
+func newCSRFToken(length int) string {
+	b := make([]byte, length)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// csrfOriginAllowed checks Origin, or Referer when Origin is absent. HTTPS requests
+// without either are rejected, plain HTTP ones are let through to the token
+// check since privacy settings may strip both.
+func csrfOriginAllowed(c *Context, trusted originMatcher) bool {
+	r := c.Request
+	scheme := c.Scheme()
+
+	origin := r.Header.Get(HeaderOrigin)
+	if origin == "" {
+		referer := r.Referer()
+		if referer == "" {
+			return scheme == "http"
+		}
+		u, err := url.Parse(referer)
+		if err != nil || u.Host == "" {
+			return false
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+
+	return strings.EqualFold(origin, scheme+"://"+r.Host) || trusted.match(origin)
+}
+
+func csrfAPIRequest(r *http.Request, config *CSRFConfig) bool {
+	if scheme, _, ok := strings.Cut(r.Header.Get(HeaderAuthorization), " "); ok {
+		for _, s := range config.APITokenSchemes {
+			if strings.EqualFold(scheme, s) {
+				return true
+			}
+		}
+	}
+	for _, h := range config.APITokenHeaders {
+		if !strings.EqualFold(h, HeaderAuthorization) && r.Header.Get(h) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// CSRFToken returns the token to embed in forms, e.g. as a hidden _csrf field.
+func (c *Context) CSRFToken() string {
+	token, _ := csrfKey.Get(c)
+	return token
+}
+
+// MemoryCSRFStore is an in-process CSRFStore whose tokens expire after TTL.
+// The zero value is ready to use and keeps tokens for the default cookie
+// lifetime of a day.
+type MemoryCSRFStore struct {
+	TTL time.Duration
+
+	mu      sync.Mutex
+	tokens  map[string]csrfEntry
+	sweepAt int
+}
+
+type csrfEntry struct {
+	token   string
+	expires time.Time
+}
+
+func NewMemoryCSRFStore(ttl time.Duration) *MemoryCSRFStore {
+	return &MemoryCSRFStore{TTL: ttl}
+}
+
+func (s *MemoryCSRFStore) Token(_ context.Context, sessionID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.tokens[sessionID]
+	if !ok || time.Now().After(e.expires) {
+		return "", nil
+	}
+	return e.token, nil
+}
+
+func (s *MemoryCSRFStore) SetToken(_ context.Context, sessionID, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tokens == nil {
+		s.tokens = make(map[string]csrfEntry)
+	}
+
+	ttl := s.TTL
+	if ttl <= 0 {
+		ttl = time.Duration(DefaultCSRFConfig.CookieMaxAge) * time.Second
+	}
+
+	now := time.Now()
+	if len(s.tokens) >= s.sweepAt {
+		for id, e := range s.tokens {
+			if now.After(e.expires) {
+				delete(s.tokens, id)
+			}
+		}
+		s.sweepAt = 2*len(s.tokens) + 64
+	}
+	s.tokens[sessionID] = csrfEntry{token: token, expires: now.Add(ttl)}
+	return nil
+}
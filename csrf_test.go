@@ -0,0 +1,45 @@
+package teta
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRFAuthorizationSchemes(t *testing.T) {
+	r := New()
+	r.Use(CSRF())
+	r.Post("/", func(c *Context) error { return c.NoContent(204) })
+
+	tests := []struct {
+		auth string
+		code int
+	}{
+		{"Basic am9lOnNlY3JldA==", 403},
+		{"Digest username=\"joe\"", 403},
+		{"Bearer token", 204},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/", nil)
+		req.Header.Set(HeaderOrigin, "https://evil.example")
+		req.Header.Set(HeaderAuthorization, tt.auth)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.code {
+			t.Errorf("%s: status = %d, want %d", tt.auth, w.Code, tt.code)
+		}
+	}
+}
+
+func TestMemoryCSRFStoreZeroValue(t *testing.T) {
+	s := &MemoryCSRFStore{}
+
+	if err := s.SetToken(context.Background(), "session", "token"); err != nil {
+		t.Fatal(err)
+	}
+	token, err := s.Token(context.Background(), "session")
+	if err != nil || token != "token" {
+		t.Fatalf("Token = %q, %v; want token", token, err)
+	}
+}
@@ -0,0 +1,212 @@
+package teta
+
+import (
+	"encoding/xml"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Encoder writes v to w in a single media type.
+type Encoder interface {
+	Encode(w io.Writer, v any) error
+}
+
+type EncoderFunc func(w io.Writer, v any) error
+
+func (f EncoderFunc) Encode(w io.Writer, v any) error {
+	return f(w, v)
+}
+
+type serializerEncoder struct {
+	serializer JSONSerializer
+	indent     string
+}
+
+func (e serializerEncoder) Encode(w io.Writer, v any) error {
+	return e.serializer.Serialize(w, v, e.indent)
+}
+
+var (
+	JSONEncoder Encoder = serializerEncoder{serializer: defaultJSONSerializer}
+	XMLEncoder  Encoder = EncoderFunc(func(w io.Writer, v any) error {
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+		return xml.NewEncoder(w).Encode(v)
+	})
+)
+
+type registeredEncoder struct {
+	mediaType   string
+	contentType string
+	encoder     Encoder
+}
+
+// EncoderRegistry maps media types to encoders. The registration order is
+// the server preference used when the client accepts several types equally.
+type EncoderRegistry struct {
+	mu       sync.RWMutex
+	encoders []registeredEncoder
+}
+
+func NewEncoderRegistry() *EncoderRegistry {
+	reg := &EncoderRegistry{}
+	reg.Register(MIMEApplicationJSON, JSONEncoder)
+	reg.Register(MIMEApplicationXMLCharsetUTF8, XMLEncoder)
+	reg.Register(MIMETextXMLCharsetUTF8, XMLEncoder)
+
+	return reg
+}
+
+// Register adds or replaces the encoder for contentType. Parameters such as
+// charset are written to the response but ignored while matching.
+func (reg *EncoderRegistry) Register(contentType string, enc Encoder) {
+	mediaType := mediaTypeOf(contentType)
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	for i := range reg.encoders {
+		if reg.encoders[i].mediaType == mediaType {
+			reg.encoders[i].contentType = contentType
+			reg.encoders[i].encoder = enc
+			return
+		}
+	}
+
+	reg.encoders = append(reg.encoders, registeredEncoder{
+		mediaType:   mediaType,
+		contentType: contentType,
+		encoder:     enc,
+	})
+}
+
+func (reg *EncoderRegistry) Unregister(contentType string) {
+	mediaType := mediaTypeOf(contentType)
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	for i := range reg.encoders {
+		if reg.encoders[i].mediaType == mediaType {
+			reg.encoders = append(reg.encoders[:i], reg.encoders[i+1:]...)
+			return
+		}
+	}
+}
+
+// Lookup returns the encoder best matching the Accept header value and the
+// Content-Type to send with it. An empty accept matches the first encoder.
+// Each encoder gets the quality of the most specific range matching it, so
+// "application/json;q=0, */*" excludes JSON.
+func (reg *EncoderRegistry) Lookup(accept string) (Encoder, string, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	if len(reg.encoders) == 0 {
+		return nil, "", false
+	}
+
+	if strings.TrimSpace(accept) == "" {
+		return reg.encoders[0].encoder, reg.encoders[0].contentType, true
+	}
+
+	specs := parseQualityValues(accept)
+
+	best, bestQ, bestSpecificity := -1, 0.0, -1
+	for i, e := range reg.encoders {
+		q, specificity := mediaTypeQuality(specs, e.mediaType)
+		if q > bestQ || q > 0 && q == bestQ && specificity > bestSpecificity {
+			best, bestQ, bestSpecificity = i, q, specificity
+		}
+	}
+	if best < 0 {
+		return nil, "", false
+	}
+
+	return reg.encoders[best].encoder, reg.encoders[best].contentType, true
+}
+
+// mediaTypeQuality returns the quality and specificity of the most specific
+// range in specs matching mediaType, or 0 and -1 when none does.
+func mediaTypeQuality(specs []acceptSpec, mediaType string) (float64, int) {
+	q, best := 0.0, -1
+	for _, spec := range specs {
+		if !matchMediaRange(spec.value, mediaType) {
+			continue
+		}
+		if s := specificity(spec.value); s > best {
+			q, best = spec.q, s
+		}
+	}
+	return q, best
+}
+
+func mediaTypeOf(contentType string) string {
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mt
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+func matchMediaRange(mediaRange, mediaType string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+
+	rangeType, rangeSub, ok := strings.Cut(mediaRange, "/")
+	if !ok || rangeSub != "*" {
+		return false
+	}
+
+	typ, _, _ := strings.Cut(mediaType, "/")
+	return rangeType == typ
+}
+
+type acceptSpec struct {
+	value string
+	q     float64
+}
+
+// parseQualityValues returns every entry of an Accept-style header in
+// order, including those rejected with q=0.
+func parseQualityValues(header string) []acceptSpec {
+	specs := make([]acceptSpec, 0, strings.Count(header, ",")+1)
+
+	for part := range strings.SplitSeq(header, ",") {
+		value, params, _ := strings.Cut(part, ";")
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+
+		q := 1.0
+		for param := range strings.SplitSeq(params, ";") {
+			name, raw, ok := strings.Cut(param, "=")
+			if !ok || strings.TrimSpace(name) != "q" {
+				continue
+			}
+			if f, err := strconv.ParseFloat(strings.TrimSpace(raw), 64); err == nil {
+				q = f
+			}
+		}
+
+		specs = append(specs, acceptSpec{value: value, q: q})
+	}
+
+	return specs
+}
+
+func specificity(mediaRange string) int {
+	switch {
+	case mediaRange == "*/*", mediaRange == "*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*"):
+		return 1
+	default:
+		return 2
+	}
+}
@@ -0,0 +1,51 @@
+package teta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEncoderRegistryLookup(t *testing.T) {
+	reg := NewEncoderRegistry()
+
+	tests := []struct {
+		accept string
+		want   string
+		ok     bool
+	}{
+		{"", MIMEApplicationJSON, true},
+		{"*/*", MIMEApplicationJSON, true},
+		{"text/xml, application/json;q=0.9", MIMETextXMLCharsetUTF8, true},
+		{"application/json;q=0, */*", MIMEApplicationXMLCharsetUTF8, true},
+		{"application/*;q=0, */*", MIMETextXMLCharsetUTF8, true},
+		{"application/*;q=0.5, application/json", MIMEApplicationJSON, true},
+		{"*/*;q=0.5, text/xml", MIMETextXMLCharsetUTF8, true},
+		{"*/*;q=0, application/json", MIMEApplicationJSON, true},
+		{"application/json;q=0", "", false},
+		{"image/png", "", false},
+	}
+	for _, tt := range tests {
+		_, contentType, ok := reg.Lookup(tt.accept)
+		if contentType != tt.want || ok != tt.ok {
+			t.Errorf("Lookup(%q) = %q, %v; want %q, %v", tt.accept, contentType, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNegotiatePrettyJSON(t *testing.T) {
+	r := New()
+	r.Get("/", func(c *Context) error {
+		return c.Negotiate(http.StatusOK, map[string]int{"a": 1})
+	})
+
+	req := httptest.NewRequest("GET", "/?pretty", nil)
+	req.Header.Set(HeaderAccept, MIMEApplicationJSON)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if !strings.Contains(w.Body.String(), "{\n  \"a\": 1\n}") {
+		t.Fatalf("body = %q, want indented JSON", w.Body.String())
+	}
+}
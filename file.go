@@ -0,0 +1,120 @@
+package teta
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// File sends the named file. Range, If-Range and the conditional request
+// headers are honoured and the content type is detected from the extension
+// or the content.
+func (c *Context) File(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fsError(err)
+	}
+	defer f.Close()
+
+	return c.sendFile(f)
+}
+
+func (c *Context) FileFS(fsys fs.FS, name string) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return fsError(err)
+	}
+	defer f.Close()
+
+	return c.sendFile(f)
+}
+
+// Attachment sends the file as a download named name. An empty name uses the
+// base name of file.
+func (c *Context) Attachment(file, name string) error {
+	return c.contentDisposition(file, name, "attachment")
+}
+
+// Inline sends the file for display in the browser, named name when saved.
+func (c *Context) Inline(file, name string) error {
+	return c.contentDisposition(file, name, "inline")
+}
+
+func (c *Context) contentDisposition(file, name, dispositionType string) error {
+	if name == "" {
+		name = filepath.Base(file)
+	}
+	c.Writer.Header().Set(HeaderContentDisposition, ContentDisposition(dispositionType, name))
+
+	return c.File(file)
+}
+
+func (c *Context) sendFile(f fs.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return fsError(err)
+	}
+	if info.IsDir() {
+		return NewHTTPError(http.StatusNotFound, http.StatusText(http.StatusNotFound))
+	}
+
+	return serveContent(c, f, info, nil, "")
+}
+
+// ContentDisposition formats a Content-Disposition value. Names that are
+// not plain ASCII get an RFC 5987 filename* parameter next to an ASCII
+// fallback for old clients.
+func ContentDisposition(dispositionType, filename string) string {
+	fallback, isASCII := asciiFilename(filename)
+
+	var b strings.Builder
+	b.WriteString(dispositionType)
+	b.WriteString(`; filename="`)
+	b.WriteString(fallback)
+	b.WriteString(`"`)
+
+	if !isASCII {
+		b.WriteString("; filename*=UTF-8''")
+		for i := 0; i < len(filename); i++ {
+			if ch := filename[i]; isAttrChar(ch) {
+				b.WriteByte(ch)
+			} else {
+				fmt.Fprintf(&b, "%%%02X", ch)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// isAttrChar reports whether ch may appear unescaped in an RFC 5987 value.
+func isAttrChar(ch byte) bool {
+	switch {
+	case 'a' <= ch && ch <= 'z', 'A' <= ch && ch <= 'Z', '0' <= ch && ch <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", ch) >= 0
+}
+
+func asciiFilename(name string) (string, bool) {
+	isASCII := true
+
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r >= 0x7f:
+			isASCII = false
+			b.WriteByte('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String(), isASCII
+}
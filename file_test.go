@@ -0,0 +1,68 @@
+package teta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		typ, name, want string
+	}{
+		{"attachment", "report.pdf", `attachment; filename="report.pdf"`},
+		{"attachment", `say "hi".txt`, `attachment; filename="say \"hi\".txt"`},
+		{"attachment", `back\slash.txt`, `attachment; filename="back\\slash.txt"`},
+		{"attachment", "résumé.pdf", `attachment; filename="r_sum_.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf`},
+		{"inline", "日本 語.txt", `inline; filename="__ _.txt"; filename*=UTF-8''%E6%97%A5%E6%9C%AC%20%E8%AA%9E.txt`},
+		{"attachment", "naïve \"q\".txt", `attachment; filename="na_ve \"q\".txt"; filename*=UTF-8''na%C3%AFve%20%22q%22.txt`},
+	}
+	for _, tt := range tests {
+		if got := ContentDisposition(tt.typ, tt.name); got != tt.want {
+			t.Errorf("ContentDisposition(%q, %q) =\n\t%s\nwant\n\t%s", tt.typ, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestContextFileRange(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "digits.txt")
+	if err := os.WriteFile(file, []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(file, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	r := New()
+	r.Get("/digits", func(c *Context) error {
+		return c.File(file)
+	})
+
+	tests := []struct {
+		name    string
+		ifRange string
+		code    int
+		body    string
+	}{
+		{"range", "", http.StatusPartialContent, "2345"},
+		{"if-range current", modified.UTC().Format(http.TimeFormat), http.StatusPartialContent, "2345"},
+		{"if-range stale", modified.Add(-time.Hour).UTC().Format(http.TimeFormat), http.StatusOK, "0123456789"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/digits", nil)
+		req.Header.Set("Range", "bytes=2-5")
+		if tt.ifRange != "" {
+			req.Header.Set("If-Range", tt.ifRange)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("%s: status = %d, body = %q; want %d, %q", tt.name, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package teta
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type JWKSConfig struct {
+	URL    string
+	Client *http.Client
+	// RefreshInterval is how long fetched keys are used before the set is
+	// downloaded again.
+	RefreshInterval time.Duration
+	// MinRefreshInterval limits the refreshes triggered by tokens signed
+	// with an unknown kid, so forged tokens cannot hammer the endpoint.
+	MinRefreshInterval time.Duration
+}
+
+var DefaultJWKSConfig = JWKSConfig{
+	Client:             &http.Client{Timeout: 10 * time.Second},
+	RefreshInterval:    time.Hour,
+	MinRefreshInterval: time.Minute,
+}
+
+const (
+	maxJWKSSize      = 1 << 20
+	jwksFetchTimeout = 10 * time.Second
+)
+
+// ErrJWKSUnavailable is returned when a token's key is not cached and the
+// key set cannot be downloaded.
+var ErrJWKSUnavailable = NewHTTPError(http.StatusServiceUnavailable, "token signing keys unavailable")
+
+// JWKS is a JWTKeyProvider backed by a JSON Web Key Set endpoint. Keys are
+// cached and refreshed periodically or when a token names an unknown kid,
+// which picks up rotated keys. If a refresh fails, the cached keys keep
+// being used.
+type JWKS struct {
+	config JWKSConfig
+
+	mu      sync.RWMutex
+	keys    map[string]any
+	fetched time.Time
+
+	refreshMu sync.Mutex
+}
+
+func NewJWKS(config JWKSConfig) *JWKS {
+	if config.Client == nil {
+		config.Client = DefaultJWKSConfig.Client
+	}
+	if config.RefreshInterval == 0 {
+		config.RefreshInterval = DefaultJWKSConfig.RefreshInterval
+	}
+	if config.MinRefreshInterval == 0 {
+		config.MinRefreshInterval = DefaultJWKSConfig.MinRefreshInterval
+	}
+
+	return &JWKS{config: config}
+}
+
+func (j *JWKS) Key(ctx context.Context, kid, _ string) (any, error) {
+	key, found, fetched := j.lookup(kid)
+	age := time.Since(fetched)
+
+	switch {
+	case found && age < j.config.RefreshInterval:
+		return key, nil
+	case !found && !fetched.IsZero() && age < j.config.MinRefreshInterval:
+		return nil, ErrJWTUnknownKey
+	}
+
+	// The download is shared by every request waiting on it, so it must not
+	// be cancelled along with the request that happened to start it.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jwksFetchTimeout)
+	defer cancel()
+
+	if err := j.refresh(ctx, fetched); err != nil {
+		if found {
+			return key, nil
+		}
+		return nil, ErrJWKSUnavailable
+	}
+
+	if key, found, _ = j.lookup(kid); !found {
+		return nil, ErrJWTUnknownKey
+	}
+	return key, nil
+}
+
+func (j *JWKS) lookup(kid string) (any, bool, time.Time) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	key, ok := j.keys[kid]
+	if !ok && kid == "" && len(j.keys) == 1 {
+		for _, k := range j.keys {
+			key, ok = k, true
+		}
+	}
+	return key, ok, j.fetched
+}
+
+// Refresh downloads the key set now.
+func (j *JWKS) Refresh(ctx context.Context) error {
+	j.mu.RLock()
+	fetched := j.fetched
+	j.mu.RUnlock()
+
+	return j.refresh(ctx, fetched)
+}
+
+// refresh downloads the set unless another request already did since seen.
+func (j *JWKS) refresh(ctx context.Context, seen time.Time) error {
+	j.refreshMu.Lock()
+	defer j.refreshMu.Unlock()
+
+	j.mu.RLock()
+	done := !j.fetched.Equal(seen)
+	j.mu.RUnlock()
+	if done {
+		return nil
+	}
+
+	keys, err := j.fetch(ctx)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// Failed attempts also count, so an unreachable endpoint is retried
+	// once per MinRefreshInterval rather than on every request.
+	j.fetched = time.Now()
+	if err != nil {
+		return err
+	}
+	j.keys = keys
+	return nil
+}
+
+func (j *JWKS) fetch(ctx context.Context) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(HeaderAccept, MIMEApplicationJSON)
+
+	resp, err := j.config.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch JWKS: unexpected status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(nil, resp.Body, maxJWKSSize)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+const minRSAKeyBits = 2048
+
+// publicKey converts RSA, P-256 and Ed25519 keys. Symmetric keys are never
+// taken from a key set.
+func (k jsonWebKey) publicKey() (any, error) {
+	switch {
+	case k.Kty == "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+
+		pub := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if pub.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key too small")
+		}
+		return pub, nil
+
+	case k.Kty == "EC" && k.Crv == "P-256":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != 32 {
+			return nil, fmt.Errorf("invalid EC key")
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil || len(y) != 32 {
+			return nil, fmt.Errorf("invalid EC key")
+		}
+
+		point := append(append([]byte{4}, x...), y...)
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+
+	case k.Kty == "OKP" && k.Crv == "Ed25519":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
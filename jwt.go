@@ -0,0 +1,329 @@
+package teta
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	JWTAlgHS256 = "HS256"
+	JWTAlgRS256 = "RS256"
+	JWTAlgES256 = "ES256"
+	JWTAlgEdDSA = "EdDSA"
+)
+
+var (
+	ErrJWTMissing          = NewHTTPError(http.StatusUnauthorized, "missing or malformed token")
+	ErrJWTMalformed        = NewHTTPError(http.StatusUnauthorized, "malformed token")
+	ErrJWTAlgorithm        = NewHTTPError(http.StatusUnauthorized, "unsupported token algorithm")
+	ErrJWTUnknownKey       = NewHTTPError(http.StatusUnauthorized, "unknown token signing key")
+	ErrJWTSignatureInvalid = NewHTTPError(http.StatusUnauthorized, "invalid token signature")
+	ErrJWTExpired          = NewHTTPError(http.StatusUnauthorized, "token is expired")
+	ErrJWTNotValidYet      = NewHTTPError(http.StatusUnauthorized, "token is not valid yet")
+	ErrJWTIssuer           = NewHTTPError(http.StatusUnauthorized, "invalid token issuer")
+	ErrJWTAudience         = NewHTTPError(http.StatusUnauthorized, "invalid token audience")
+)
+
+// RegisteredClaims are the RFC 7519 claims checked by the JWT middleware.
+// Embed it in application claim structs.
+type RegisteredClaims struct {
+	Issuer    string       `json:"iss,omitempty"`
+	Subject   string       `json:"sub,omitempty"`
+	Audience  Audience     `json:"aud,omitempty"`
+	ExpiresAt *NumericDate `json:"exp,omitempty"`
+	NotBefore *NumericDate `json:"nbf,omitempty"`
+	IssuedAt  *NumericDate `json:"iat,omitempty"`
+	ID        string       `json:"jti,omitempty"`
+}
+
+func (c *RegisteredClaims) Registered() *RegisteredClaims {
+	return c
+}
+
+// Claims is implemented by every struct embedding RegisteredClaims.
+type Claims interface {
+	Registered() *RegisteredClaims
+}
+
+// Audience accepts both the single string and the array form of "aud".
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*a = Audience{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(a))
+}
+
+// NumericDate is a JSON number of seconds since the Unix epoch.
+type NumericDate struct {
+	time.Time
+}
+
+func NewNumericDate(t time.Time) *NumericDate {
+	return &NumericDate{t.Truncate(time.Second)}
+}
+
+func (d NumericDate) MarshalJSON() ([]byte, error) {
+	return strconv.AppendInt(nil, d.Unix(), 10), nil
+}
+
+func (d *NumericDate) UnmarshalJSON(b []byte) error {
+	f, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return err
+	}
+	sec, frac := math.Modf(f)
+	d.Time = time.Unix(int64(sec), int64(frac*1e9))
+	return nil
+}
+
+// JWTKeyProvider resolves the verification key of a token from the kid and
+// alg of its header. Keys are []byte for HS256, *rsa.PublicKey for RS256,
+// *ecdsa.PublicKey on P-256 for ES256 and ed25519.PublicKey for EdDSA.
+type JWTKeyProvider interface {
+	Key(ctx context.Context, kid, alg string) (any, error)
+}
+
+// JWTKeys is a static JWTKeyProvider indexed by kid. Tokens without a kid
+// match the "" entry, or the only key of the set.
+type JWTKeys map[string]any
+
+func (k JWTKeys) Key(_ context.Context, kid, _ string) (any, error) {
+	if key, ok := k[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(k) == 1 {
+		for _, key := range k {
+			return key, nil
+		}
+	}
+	return nil, ErrJWTUnknownKey
+}
+
+type JWTConfig[T any] struct {
+	Skipper Skipper
+	Keys    JWTKeyProvider
+	// Algorithms restricts the accepted "alg" values. Defaults to HS256,
+	// RS256, ES256 and EdDSA; "none" is never accepted.
+	Algorithms []string
+	// TokenLookup lists where the token is sent, as in APIKeyConfig. By
+	// default it is read from an "Authorization: Bearer" header.
+	TokenLookup string
+	// Issuer and Audience, when set, must match the "iss" and "aud" claims.
+	Issuer   string
+	Audience string
+	// ClockSkew is tolerated when checking "exp" and "nbf".
+	ClockSkew time.Duration
+	// ClaimsKey receives the verified claims, e.g.
+	//
+	//	var ClaimsKey = teta.NewKey[*MyClaims]("claims")
+	ClaimsKey *Key[*T]
+	Realm     string
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+var defaultJWTAlgorithms = []string{JWTAlgHS256, JWTAlgRS256, JWTAlgES256, JWTAlgEdDSA}
+
+// JWT authenticates bearer JSON Web Tokens, decoding the claims into T. The
+// claims are the request principal, their subject names the user in the
+// request logs, and ClaimsKey exposes them typed:
+//
+//	r.Use(teta.JWT(teta.JWTConfig[MyClaims]{Keys: jwks, ClaimsKey: ClaimsKey}))
+func JWT[T any, PT interface {
+	*T
+	Claims
+}](config JWTConfig[T]) Middleware {
+	if config.Keys == nil {
+		panic("teta: JWT requires Keys")
+	}
+	if len(config.Algorithms) == 0 {
+		config.Algorithms = defaultJWTAlgorithms
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	var lookups []func(c *Context) string
+	if config.TokenLookup != "" {
+		lookups = parseValueLookup(config.TokenLookup)
+	}
+	challenge := `Bearer realm=` + strconv.Quote(realmOr(config.Realm))
+
+	return authMiddleware(config.Skipper, func(c *Context) (any, string, error) {
+		token := ""
+		if lookups == nil {
+			token, _ = bearerToken(c.Request)
+		}
+		for _, lookup := range lookups {
+			if token = lookup(c); token != "" {
+				break
+			}
+		}
+		if token == "" {
+			return nil, challenge, ErrJWTMissing
+		}
+
+		claims, err := ParseJWT[T, PT](c.Context(), token, config)
+		if err != nil {
+			return nil, challenge + `, error="invalid_token"`, err
+		}
+
+		if config.ClaimsKey != nil {
+			config.ClaimsKey.Set(c, claims)
+		}
+		if sub := PT(claims).Registered().Subject; sub != "" {
+			UserKey.Set(c, sub)
+		}
+		return claims, challenge, nil
+	})
+}
+
+// ParseJWT verifies token the way the JWT middleware does and returns its
+// claims, for tokens that do not arrive over HTTP.
+func ParseJWT[T any, PT interface {
+	*T
+	Claims
+}](ctx context.Context, token string, config JWTConfig[T]) (*T, error) {
+	headerPart, rest, ok1 := strings.Cut(token, ".")
+	payloadPart, sigPart, ok2 := strings.Cut(rest, ".")
+	if !ok1 || !ok2 || strings.Contains(sigPart, ".") {
+		return nil, ErrJWTMalformed
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(headerPart, &header); err != nil {
+		return nil, ErrJWTMalformed
+	}
+
+	algorithms := config.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = defaultJWTAlgorithms
+	}
+	if !slices.Contains(algorithms, header.Alg) {
+		return nil, ErrJWTAlgorithm
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(sigPart)
+	if err != nil {
+		return nil, ErrJWTMalformed
+	}
+
+	key, err := config.Keys.Key(ctx, header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyJWTSignature(header.Alg, key, token[:len(headerPart)+1+len(payloadPart)], sig); err != nil {
+		return nil, err
+	}
+
+	claims := new(T)
+	if err := decodeJWTPart(payloadPart, claims); err != nil {
+		return nil, ErrJWTMalformed
+	}
+
+	now := time.Now()
+	if config.Now != nil {
+		now = config.Now()
+	}
+	if err := validateJWTClaims(PT(claims).Registered(), now, config.ClockSkew, config.Issuer, config.Audience); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+// verifyJWTSignature checks the key type against alg, so a public key can
+// never be used as an HMAC secret.
+func verifyJWTSignature(alg string, key any, signed string, sig []byte) error {
+	sum := sha256.Sum256([]byte(signed))
+	valid := false
+
+	switch alg {
+	case JWTAlgHS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrJWTUnknownKey
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signed))
+		valid = hmac.Equal(sig, mac.Sum(nil))
+	case JWTAlgRS256:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrJWTUnknownKey
+		}
+		valid = rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig) == nil
+	case JWTAlgES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve.Params().Name != "P-256" {
+			return ErrJWTUnknownKey
+		}
+		if len(sig) == 64 {
+			r := new(big.Int).SetBytes(sig[:32])
+			s := new(big.Int).SetBytes(sig[32:])
+			valid = ecdsa.Verify(pub, sum[:], r, s)
+		}
+	case JWTAlgEdDSA:
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok || len(pub) != ed25519.PublicKeySize {
+			return ErrJWTUnknownKey
+		}
+		valid = ed25519.Verify(pub, []byte(signed), sig)
+	default:
+		return ErrJWTAlgorithm
+	}
+
+	if !valid {
+		return ErrJWTSignatureInvalid
+	}
+	return nil
+}
+
+func validateJWTClaims(claims *RegisteredClaims, now time.Time, skew time.Duration, issuer, audience string) error {
+	if claims.ExpiresAt != nil && !now.Before(claims.ExpiresAt.Add(skew)) {
+		return ErrJWTExpired
+	}
+	if claims.NotBefore != nil && now.Add(skew).Before(claims.NotBefore.Time) {
+		return ErrJWTNotValidYet
+	}
+	if issuer != "" && claims.Issuer != issuer {
+		return ErrJWTIssuer
+	}
+	if audience != "" && !slices.Contains(claims.Audience, audience) {
+		return ErrJWTAudience
+	}
+	return nil
+}
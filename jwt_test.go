@@ -0,0 +1,264 @@
+package teta
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testClaims struct {
+	RegisteredClaims
+	Name string `json:"name"`
+}
+
+var testRSAKey = sync.OnceValue(func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+})
+
+func signJWT(t *testing.T, alg, kid string, key any, claims any) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	var err error
+	switch alg {
+	case JWTAlgHS256:
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case JWTAlgRS256:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, sum[:])
+	case JWTAlgES256:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), sum[:])
+		if err == nil {
+			sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	case JWTAlgEdDSA:
+		sig = ed25519.Sign(key.(ed25519.PrivateKey), []byte(signed))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWTAlgorithms(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	tests := []struct {
+		alg       string
+		signKey   any
+		verifyKey any
+	}{
+		{JWTAlgHS256, secret, secret},
+		{JWTAlgRS256, testRSAKey(), &testRSAKey().PublicKey},
+		{JWTAlgES256, ecKey, &ecKey.PublicKey},
+		{JWTAlgEdDSA, edKey, edPub},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			config := JWTConfig[testClaims]{Keys: JWTKeys{"k1": tt.verifyKey}}
+			token := signJWT(t, tt.alg, "k1", tt.signKey, testClaims{Name: "alice"})
+
+			claims, err := ParseJWT[testClaims](context.Background(), token, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Name != "alice" {
+				t.Fatalf("name = %q, want alice", claims.Name)
+			}
+
+			tampered := token[:len(token)-4] + "AAAA"
+			if _, err := ParseJWT[testClaims](context.Background(), tampered, config); err != ErrJWTSignatureInvalid {
+				t.Fatalf("tampered token: err = %v, want ErrJWTSignatureInvalid", err)
+			}
+		})
+	}
+}
+
+// A token signed with HS256 using the RSA public key as the secret must not
+// verify against that public key.
+func TestJWTPublicKeyAsHMACSecret(t *testing.T) {
+	pub := &testRSAKey().PublicKey
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := JWTConfig[testClaims]{Keys: JWTKeys{"k1": pub}}
+	token := signJWT(t, JWTAlgHS256, "k1", der, testClaims{Name: "mallory"})
+
+	if _, err := ParseJWT[testClaims](context.Background(), token, config); err != ErrJWTUnknownKey {
+		t.Fatalf("err = %v, want ErrJWTUnknownKey", err)
+	}
+}
+
+func TestJWTTimeClaims(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name   string
+		claims RegisteredClaims
+		want   error
+	}{
+		{"valid", RegisteredClaims{ExpiresAt: NewNumericDate(now.Add(time.Minute))}, nil},
+		{"expired", RegisteredClaims{ExpiresAt: NewNumericDate(now.Add(-time.Minute))}, ErrJWTExpired},
+		{"expired within skew", RegisteredClaims{ExpiresAt: NewNumericDate(now.Add(-10 * time.Second))}, nil},
+		{"expired at skew edge", RegisteredClaims{ExpiresAt: NewNumericDate(now.Add(-30 * time.Second))}, ErrJWTExpired},
+		{"not valid yet", RegisteredClaims{NotBefore: NewNumericDate(now.Add(time.Minute))}, ErrJWTNotValidYet},
+		{"not before within skew", RegisteredClaims{NotBefore: NewNumericDate(now.Add(10 * time.Second))}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := JWTConfig[testClaims]{
+				Keys:      JWTKeys{"": secret},
+				ClockSkew: 30 * time.Second,
+				Now:       func() time.Time { return now },
+			}
+			token := signJWT(t, JWTAlgHS256, "", secret, testClaims{RegisteredClaims: tt.claims})
+
+			if _, err := ParseJWT[testClaims](context.Background(), token, config); err != tt.want {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestJWTIssuerAudience(t *testing.T) {
+	secret := []byte("secret")
+	config := JWTConfig[testClaims]{
+		Keys:     JWTKeys{"": secret},
+		Issuer:   "https://issuer.example",
+		Audience: "api",
+	}
+
+	tests := []struct {
+		name   string
+		claims RegisteredClaims
+		want   error
+	}{
+		{"valid", RegisteredClaims{Issuer: "https://issuer.example", Audience: Audience{"web", "api"}}, nil},
+		{"wrong issuer", RegisteredClaims{Issuer: "https://evil.example", Audience: Audience{"api"}}, ErrJWTIssuer},
+		{"wrong audience", RegisteredClaims{Issuer: "https://issuer.example", Audience: Audience{"web"}}, ErrJWTAudience},
+		{"no audience", RegisteredClaims{Issuer: "https://issuer.example"}, ErrJWTAudience},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := signJWT(t, JWTAlgHS256, "", secret, testClaims{RegisteredClaims: tt.claims})
+
+			if _, err := ParseJWT[testClaims](context.Background(), token, config); err != tt.want {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func rsaJWK(kid string, pub *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+func TestJWKSKeyRotation(t *testing.T) {
+	oldKey := testRSAKey()
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	var (
+		mu      sync.Mutex
+		keys    = []map[string]string{rsaJWK("old", &oldKey.PublicKey)}
+		fetches atomic.Int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		mu.Lock()
+		defer mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	}))
+	defer srv.Close()
+
+	jwks := NewJWKS(JWKSConfig{URL: srv.URL, MinRefreshInterval: time.Nanosecond})
+	config := JWTConfig[testClaims]{Keys: jwks}
+
+	token := signJWT(t, JWTAlgRS256, "old", oldKey, testClaims{})
+	if _, err := ParseJWT[testClaims](context.Background(), token, config); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	keys = append(keys, rsaJWK("new", &newKey.PublicKey))
+	mu.Unlock()
+
+	token = signJWT(t, JWTAlgRS256, "new", newKey, testClaims{})
+	if _, err := ParseJWT[testClaims](context.Background(), token, config); err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Fatalf("fetches = %d, want 2", n)
+	}
+
+	token = signJWT(t, JWTAlgRS256, "gone", newKey, testClaims{})
+	if _, err := ParseJWT[testClaims](context.Background(), token, config); err != ErrJWTUnknownKey {
+		t.Fatalf("unknown kid: err = %v, want ErrJWTUnknownKey", err)
+	}
+}
+
+func TestJWKSUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	jwks := NewJWKS(JWKSConfig{URL: srv.URL})
+
+	if _, err := jwks.Key(context.Background(), "k1", JWTAlgRS256); err != ErrJWKSUnavailable {
+		t.Fatalf("err = %v, want ErrJWKSUnavailable", err)
+	}
+}
+
+// The key set download is shared, so a cancelled request must not abort it.
+func TestJWKSRefreshIgnoresRequestCancellation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []any{rsaJWK("k1", &testRSAKey().PublicKey)}})
+	}))
+	defer srv.Close()
+
+	jwks := NewJWKS(JWKSConfig{URL: srv.URL})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := jwks.Key(ctx, "k1", JWTAlgRS256); err != nil {
+		t.Fatal(err)
+	}
+}
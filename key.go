@@ -0,0 +1,61 @@
+package teta
+
+import (
+	"context"
+	"net/http"
+)
+
+// Key is a typed request-scoped value. Keys compare by identity, so two keys
+// with the same name never collide; packages usually publish them as
+// variables:
+//
+//	var UserKey = teta.NewKey[*User]("auth.user")
+//
+// Values are stored on the Context and are also visible through
+// c.Request.Context(), so Value works from plain net/http code.
+type Key[T any] struct {
+	name string
+}
+
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+func (k *Key[T]) String() string {
+	return k.name
+}
+
+func (k *Key[T]) Set(c *Context, value T) {
+	c.valueStore().setTyped(k, value)
+}
+
+func (k *Key[T]) Get(c *Context) (T, bool) {
+	if c.store != nil {
+		if v, ok := c.store.getTyped(k); ok {
+			value, _ := v.(T)
+			return value, true
+		}
+	}
+	return k.Value(c.Request.Context())
+}
+
+// MustGet returns the value or a 500 HTTPError when it was never set, which
+// usually means a middleware is missing from the chain.
+func (k *Key[T]) MustGet(c *Context) (T, error) {
+	v, ok := k.Get(c)
+	if !ok {
+		return v, NewHTTPError(http.StatusInternalServerError, "missing context value "+k.name)
+	}
+	return v, nil
+}
+
+func (k *Key[T]) Value(ctx context.Context) (T, bool) {
+	v, ok := ctx.Value(k).(T)
+	return v, ok
+}
+
+// WithValue returns a copy of ctx carrying value, for code that works with
+// a plain context.Context.
+func (k *Key[T]) WithValue(ctx context.Context, value T) context.Context {
+	return context.WithValue(ctx, k, value)
+}
@@ -1,23 +1,141 @@
 package teta
 
 import (
+	"context"
 	"io"
 	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
 )
 
+// LevelFatal is logged by Fatal right before the process exits. Handlers
+// built by NewLogger print it as FATAL.
+const LevelFatal = slog.Level(12)
+
+type LogFormat int
+
+const (
+	LogFormatJSON LogFormat = iota
+	LogFormatText
+)
+
+type LoggerConfig struct {
+	// Level is the initial minimum level. It can be changed at runtime
+	// through TetaLogger.SetLevel or LogLevelHandler.
+	Level  slog.Level
+	Format LogFormat
+	// TimeKey, LevelKey and MessageKey rename the built-in attributes.
+	// Empty values keep the slog names.
+	TimeKey    string
+	LevelKey   string
+	MessageKey string
+	AddSource  bool
+	// SetDefault installs the logger as the process-wide slog default. It is
+	// off by default, so creating a router does not change global state.
+	SetDefault bool
+	// ExitFunc is called by Fatal with status 1. Defaults to os.Exit;
+	// tests can replace it with a stub.
+	ExitFunc func(code int)
+}
+
+var DefaultLoggerConfig = LoggerConfig{
+	Level:      slog.LevelDebug,
+	Format:     LogFormatJSON,
+	TimeKey:    "timestamp",
+	LevelKey:   "level",
+	MessageKey: "message",
+}
+
 func newLogger(w io.Writer) *TetaLogger {
-	logger := setupLogger(w)
+	return NewLogger(w, DefaultLoggerConfig)
+}
 
-	slog.SetDefault(logger)
-	return &TetaLogger{
-		handler: logger,
-		output:  w,
+func NewLogger(w io.Writer, config LoggerConfig) *TetaLogger {
+	level := new(slog.LevelVar)
+	level.Set(config.Level)
+
+	l := &TetaLogger{
+		level:  level,
+		config: config,
 	}
+	l.SetOutput(w)
+
+	return l
+}
+
+// NewSlogLogger returns a Logger that writes through h, for example a handler
+// shipping records to a log collector.
+func NewSlogLogger(h slog.Handler) *TetaLogger {
+	return NewLoggerFromSlog(slog.New(h))
+}
+
+// NewLoggerFromSlog adapts an existing *slog.Logger, keeping its handler,
+// attributes and groups. The handler owns the level and the destination:
+// SetLevel and SetOutput have no effect and LevelVar returns nil.
+func NewLoggerFromSlog(l *slog.Logger) *TetaLogger {
+	return &TetaLogger{handler: l}
+}
+
+// UserKey holds the identifier of the authenticated user. Authentication
+// middleware sets it so request logs can name the user.
+var UserKey = NewKey[string]("user")
+
+// Logger returns the router Logger enriched with the request ID, method,
+// route and user of the request, so handler logs are correlated without
+// passing fields around. The logger is cached until one of those changes.
+func (c *Context) Logger() Logger {
+	id := c.RequestID()
+	user, _ := UserKey.Get(c)
+
+	if c.log.logger != nil && c.log.requestID == id && c.log.user == user {
+		return c.log.logger
+	}
+
+	var base Logger = defaultLogger
+	if c.router != nil && c.router.Logger != nil {
+		base = c.router.Logger
+	}
+
+	fields := make([]any, 0, 8)
+	if id != "" {
+		fields = append(fields, "request_id", id)
+	}
+	fields = append(fields, "method", c.Request.Method)
+	if c.Request.Pattern != "" {
+		fields = append(fields, "route", c.Request.Pattern)
+	}
+	if user != "" {
+		fields = append(fields, "user", user)
+	}
+
+	c.log = requestLogger{
+		logger:    base.WithFields(fields...),
+		requestID: id,
+		user:      user,
+	}
+	return c.log.logger
+}
+
+type requestLogger struct {
+	logger    Logger
+	requestID string
+	user      string
+}
+
+// defaultLogger serves contexts created outside a Router. It writes through
+// slog.Default and never replaces it.
+var defaultLogger = &TetaLogger{
+	handler: slog.Default(),
+	output:  os.Stderr,
+	level:   new(slog.LevelVar),
 }
 
 type TetaLogger struct {
 	handler *slog.Logger
 	output  io.Writer
+	level   *slog.LevelVar
+	config  LoggerConfig
 }
 
 type Logger interface {
@@ -29,7 +147,10 @@ type Logger interface {
 	Info(msg string, args ...any)
 	Warn(msg string, args ...any)
 	Error(msg string, args ...any)
+	// Fatal logs at LevelFatal and exits the process.
 	Fatal(msg string, args ...any)
+	// Panic logs at Error level and panics with msg.
+	Panic(msg string, args ...any)
 }
 
 func (l *TetaLogger) Output() io.Writer {
@@ -37,17 +158,69 @@ func (l *TetaLogger) Output() io.Writer {
 }
 
 func (l *TetaLogger) SetOutput(w io.Writer) {
+	if l.level == nil {
+		return
+	}
+
 	l.output = w
-	l.handler = setupLogger(w)
+	l.handler = setupLogger(w, l.config, l.level)
+
+	if l.config.SetDefault {
+		slog.SetDefault(l.handler)
+	}
 }
 
+// WithFields returns a logger that adds args to every record. Fields are
+// added inside the groups opened by WithGroup.
 func (l *TetaLogger) WithFields(args ...any) Logger {
+	return l.derive(l.handler.With(args...))
+}
+
+// WithGroup returns a logger that nests all later fields under name.
+func (l *TetaLogger) WithGroup(name string) Logger {
+	return l.derive(l.handler.WithGroup(name))
+}
+
+func (l *TetaLogger) derive(handler *slog.Logger) *TetaLogger {
 	return &TetaLogger{
-		handler: l.handler.With(args...),
+		handler: handler,
 		output:  l.output,
+		level:   l.level,
+		config:  l.config,
 	}
 }
 
+// Slog returns the underlying *slog.Logger.
+func (l *TetaLogger) Slog() *slog.Logger {
+	return l.handler
+}
+
+// Level returns the current minimum level, shared with every logger derived
+// through WithFields. For handler-backed loggers it is the lowest standard
+// level the handler enables.
+func (l *TetaLogger) Level() slog.Level {
+	if l.level != nil {
+		return l.level.Level()
+	}
+
+	for _, level := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError} {
+		if l.handler.Enabled(context.Background(), level) {
+			return level
+		}
+	}
+	return LevelFatal
+}
+
+func (l *TetaLogger) SetLevel(level slog.Level) {
+	if l.level != nil {
+		l.level.Set(level)
+	}
+}
+
+func (l *TetaLogger) LevelVar() *slog.LevelVar {
+	return l.level
+}
+
 func (l *TetaLogger) Debug(msg string, args ...any) {
 	l.handler.Debug(msg, args...)
 }
@@ -65,25 +238,95 @@ func (l *TetaLogger) Error(msg string, args ...any) {
 }
 
 func (l *TetaLogger) Fatal(msg string, args ...any) {
+	l.handler.Log(context.Background(), LevelFatal, msg, args...)
+
+	exit := l.config.ExitFunc
+	if exit == nil {
+		exit = os.Exit
+	}
+	exit(1)
+}
+
+// Panic logs at error level and panics with "FATAL: " + msg, which is what
+// Fatal used to do.
+func (l *TetaLogger) Panic(msg string, args ...any) {
 	l.handler.Error(msg, args...)
 	panic("FATAL: " + msg)
 }
 
-func setupLogger(w io.Writer) *slog.Logger {
-	jsonHandler := slog.NewJSONHandler(w, &slog.HandlerOptions{
-		Level: slog.LevelDebug,
+type logLevelMessage struct {
+	Level string `json:"level"`
+}
+
+// LogLevelHandler exposes level for operators: GET reports it, PUT and POST
+// change it from a {"level":"debug"} body or a ?level= query parameter.
+// Mount it behind authentication, for example:
+//
+//	admin.Get("/log-level", teta.LogLevelHandler(logger.LevelVar()))
+//	admin.Put("/log-level", teta.LogLevelHandler(logger.LevelVar()))
+func LogLevelHandler(level *slog.LevelVar) HandlerFunc {
+	return func(c *Context) error {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut, http.MethodPost:
+			msg := logLevelMessage{Level: c.Request.URL.Query().Get("level")}
+			if msg.Level == "" {
+				if err := c.JSONSerializer().Deserialize(c.Request.Body, &msg); err != nil {
+					return NewHTTPError(http.StatusBadRequest, "invalid log level request")
+				}
+			}
+
+			var l slog.Level
+			if err := l.UnmarshalText([]byte(msg.Level)); err != nil {
+				return NewHTTPError(http.StatusBadRequest, "invalid log level "+strconv.Quote(msg.Level))
+			}
+			level.Set(l)
+		default:
+			return NewHTTPError(http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+		}
+
+		return c.JSON(http.StatusOK, logLevelMessage{Level: level.Level().String()})
+	}
+}
+
+func setupLogger(w io.Writer, config LoggerConfig, level slog.Leveler) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:     level,
+		AddSource: config.AddSource,
 		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) > 0 {
+				return a
+			}
+
 			switch a.Key {
 			case slog.TimeKey:
-				a.Key = "timestamp"
+				a.Key = keyOr(config.TimeKey, a.Key)
 			case slog.LevelKey:
-				a.Key = "level"
+				a.Key = keyOr(config.LevelKey, a.Key)
+				if level, ok := a.Value.Any().(slog.Level); ok && level == LevelFatal {
+					a.Value = slog.StringValue("FATAL")
+				}
 			case slog.MessageKey:
-				a.Key = "message"
+				a.Key = keyOr(config.MessageKey, a.Key)
 			}
 			return a
 		},
-	})
+	}
 
-	return slog.New(jsonHandler)
+	var handler slog.Handler
+	switch config.Format {
+	case LogFormatText:
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		handler = slog.NewJSONHandler(w, opts)
+	}
+
+	return slog.New(handler)
+}
+
+func keyOr(key, fallback string) string {
+	if key == "" {
+		return fallback
+	}
+	return key
 }
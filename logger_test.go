@@ -0,0 +1,33 @@
+package teta
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestLoggerSetDefault(t *testing.T) {
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	New()
+	if slog.Default() != previous {
+		t.Fatal("New replaced the slog default")
+	}
+
+	config := DefaultLoggerConfig
+	config.SetDefault = true
+	NewLogger(io.Discard, config)
+	if slog.Default() == previous {
+		t.Fatal("SetDefault did not install the logger")
+	}
+}
+
+func TestLoggerPanicValue(t *testing.T) {
+	defer func() {
+		if v := recover(); v != "FATAL: boom" {
+			t.Fatalf("panic value = %v, want %q", v, "FATAL: boom")
+		}
+	}()
+	newLogger(io.Discard).Panic("boom")
+}
@@ -0,0 +1,52 @@
+package teta
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Skipper reports whether a middleware should be bypassed for the request.
+type Skipper func(c *Context) bool
+
+func DefaultSkipper(c *Context) bool {
+	return false
+}
+
+// parseValueLookup turns a comma separated list of "header:<name>",
+// "form:<field>", "query:<param>" or "cookie:<name>" sources into functions
+// extracting the value from a request.
+func parseValueLookup(spec string) []func(c *Context) string {
+	var lookups []func(c *Context) string
+
+	for _, source := range strings.Split(spec, ",") {
+		kind, name, ok := strings.Cut(strings.TrimSpace(source), ":")
+		if !ok || name == "" {
+			panic(fmt.Sprintf("teta: invalid value lookup %q", source))
+		}
+
+		switch kind {
+		case "header":
+			lookups = append(lookups, func(c *Context) string {
+				return c.Request.Header.Get(name)
+			})
+		case "form":
+			lookups = append(lookups, func(c *Context) string {
+				return c.Request.PostFormValue(name)
+			})
+		case "query":
+			lookups = append(lookups, func(c *Context) string {
+				return c.Request.URL.Query().Get(name)
+			})
+		case "cookie":
+			lookups = append(lookups, func(c *Context) string {
+				if cookie, err := c.Request.Cookie(name); err == nil {
+					return cookie.Value
+				}
+				return ""
+			})
+		default:
+			panic(fmt.Sprintf("teta: invalid value lookup %q", source))
+		}
+	}
+	return lookups
+}
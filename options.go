@@ -0,0 +1,90 @@
+package teta
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ServeHTTP dispatches to the ServeMux. OPTIONS requests for paths without
+// an Options or Any route are answered here with a 204 and an Allow header,
+// after the middleware of the group that registered the route, so CORS can
+// answer preflight requests without extra mux patterns.
+func (t *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		if h := t.preflightHandler(w, r); h != nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+	}
+	t.handler.ServeHTTP(w, r)
+}
+
+// addPreflight records the OPTIONS handler of a route, run with the
+// middleware of rg.
+func (t *Router) addPreflight(rg *RouterGroup, method, pattern string) {
+	t.preflightMu.Lock()
+	defer t.preflightMu.Unlock()
+
+	if t.preflight == nil {
+		t.preflight = make(map[string]http.Handler)
+	}
+	t.preflight[pattern] = rg.next(answerOptions)
+
+	if !slices.Contains(t.methods, method) {
+		t.methods = append(t.methods, method)
+	}
+}
+
+// preflightHandler probes the mux with every registered method. It returns
+// nil when an explicit route handles OPTIONS or nothing matches the path.
+// The handler of the route for Access-Control-Request-Method is preferred,
+// so preflight runs through the CORS middleware of that route.
+func (t *Router) preflightHandler(w http.ResponseWriter, r *http.Request) http.Handler {
+	if _, pattern := t.handler.Handler(r); pattern != "" {
+		return nil
+	}
+
+	t.preflightMu.RLock()
+	defer t.preflightMu.RUnlock()
+
+	requested := r.Header.Get(HeaderAccessControlRequestMethod)
+	allowed := make([]string, 0, len(t.methods)+2)
+	var handler, fallback http.Handler
+
+	probe := *r
+	for _, method := range t.methods {
+		probe.Method = method
+		_, pattern := t.handler.Handler(&probe)
+		h := t.preflight[pattern]
+		if h == nil {
+			continue
+		}
+
+		allowed = append(allowed, method)
+		if fallback == nil {
+			fallback = h
+		}
+		if method == requested {
+			handler = h
+		}
+	}
+	if fallback == nil {
+		return nil
+	}
+	if handler == nil {
+		handler = fallback
+	}
+
+	if slices.Contains(allowed, http.MethodGet) && !slices.Contains(allowed, http.MethodHead) {
+		allowed = append(allowed, http.MethodHead)
+	}
+	allowed = append(allowed, http.MethodOptions)
+	w.Header().Set(HeaderAllow, strings.Join(allowed, ", "))
+
+	return handler
+}
+
+func answerOptions(c *Context) error {
+	return c.NoContent(http.StatusNoContent)
+}
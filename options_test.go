@@ -0,0 +1,74 @@
+package teta
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOptionsOverlappingRoutes(t *testing.T) {
+	r := New()
+	r.Get("/a/{x}", func(c *Context) error { return nil })
+	r.Post("/{y}/b", func(c *Context) error { return nil })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("OPTIONS", "/a/b", nil))
+
+	if w.Code != 204 {
+		t.Fatalf("status = %d, want 204", w.Code)
+	}
+	if got := w.Header().Get(HeaderAllow); got != "GET, POST, HEAD, OPTIONS" {
+		t.Fatalf("Allow = %q", got)
+	}
+}
+
+func TestOptionsPreflightUsesRouteMiddleware(t *testing.T) {
+	r := New()
+	r.Get("/items", func(c *Context) error { return nil })
+	r.With(CORSWithConfig(CORSConfig{AllowOrigins: []string{"https://app.example"}})).
+		Post("/items", func(c *Context) error { return nil })
+
+	req := httptest.NewRequest("OPTIONS", "/items", nil)
+	req.Header.Set(HeaderOrigin, "https://app.example")
+	req.Header.Set(HeaderAccessControlRequestMethod, "POST")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if got := w.Header().Get(HeaderAccessControlAllowOrigin); got != "https://app.example" {
+		t.Fatalf("Access-Control-Allow-Origin = %q", got)
+	}
+}
+
+func TestOptionsExplicitRoutes(t *testing.T) {
+	r := New()
+	r.Get("/x", func(c *Context) error { return nil })
+	r.Options("/x", func(c *Context) error { return c.String(200, "custom") })
+	r.Any("/any", func(c *Context) error { return c.String(200, "any") })
+	r.Get("/any", func(c *Context) error { return nil })
+
+	for path, want := range map[string]string{"/x": "custom", "/any": "any"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("OPTIONS", path, nil))
+		if w.Body.String() != want {
+			t.Errorf("OPTIONS %s = %q, want %q", path, w.Body.String(), want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("OPTIONS", "/missing", nil))
+	if w.Code != 404 {
+		t.Errorf("OPTIONS /missing = %d, want 404", w.Code)
+	}
+}
+
+func TestHandleDropsTrailingSlash(t *testing.T) {
+	r := New()
+	r.Get("/users/", func(c *Context) error { return c.NoContent(204) })
+
+	for path, want := range map[string]int{"/users": 204, "/users/anything": 404} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != want {
+			t.Errorf("GET %s = %d, want %d", path, w.Code, want)
+		}
+	}
+}
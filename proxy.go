@@ -0,0 +1,215 @@
+package teta
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// SetTrustedProxies lists the CIDRs, or single addresses, of the proxies
+// and load balancers in front of the router. Forwarding headers are only
+// honoured by RealIP and Scheme when the request comes from one of them.
+func (t *Router) SetTrustedProxies(cidrs ...string) error {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(cidr)
+			if addrErr != nil {
+				return fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	t.trustedProxies = prefixes
+	return nil
+}
+
+func (t *Router) trustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range t.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// RealIP returns the client address. When the peer is a trusted proxy, it
+// walks the RFC 7239 Forwarded header, or else X-Forwarded-For, from the
+// nearest hop and returns the first address that is not a trusted proxy;
+// X-Real-Ip is used when neither is present. Otherwise it is the peer
+// address of the connection.
+func (c *Context) RealIP() string {
+	peer, ok := peerAddr(c.Request.RemoteAddr)
+	if !ok {
+		return c.Request.RemoteAddr
+	}
+	if !c.trustsPeer(peer) {
+		return peer.String()
+	}
+
+	h := c.Request.Header
+	if values := h.Values(HeaderForwarded); len(values) > 0 {
+		return c.forwardedClient(peer, forwardedParams(values, "for"))
+	}
+	if values := h.Values(HeaderXForwardedFor); len(values) > 0 {
+		var hops []string
+		for _, v := range values {
+			hops = append(hops, strings.Split(v, ",")...)
+		}
+		return c.forwardedClient(peer, hops)
+	}
+	if ip, err := netip.ParseAddr(strings.TrimSpace(h.Get(HeaderXRealIP))); err == nil {
+		return ip.Unmap().String()
+	}
+
+	return peer.String()
+}
+
+// forwardedClient returns the rightmost hop that is not a trusted proxy.
+// Hops that do not parse, like obfuscated RFC 7239 identifiers, end the
+// walk at the last known address.
+func (c *Context) forwardedClient(peer netip.Addr, hops []string) string {
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := hopAddr(hops[i])
+		if !ok {
+			break
+		}
+		client = addr
+		if !c.router.trustedProxy(addr) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// Scheme returns "https" for TLS requests, or when a trusted proxy says so
+// through Forwarded, X-Forwarded-Proto, X-Forwarded-Protocol,
+// X-Forwarded-Ssl or X-Url-Scheme, and "http" otherwise. Like RealIP, it
+// takes the proto recorded by the outermost trusted proxy in Forwarded, and
+// the nearest hop's value in X-Forwarded-Proto, as a client can prepend any
+// value it likes.
+func (c *Context) Scheme() string {
+	r := c.Request
+	if r.TLS != nil {
+		return "https"
+	}
+
+	peer, ok := peerAddr(r.RemoteAddr)
+	if !ok || !c.trustsPeer(peer) {
+		return "http"
+	}
+
+	h := r.Header
+	var scheme string
+	if values := h.Values(HeaderForwarded); len(values) > 0 {
+		scheme = c.forwardedProto(values)
+	}
+	if values := h.Values(HeaderXForwardedProto); scheme == "" && len(values) > 0 {
+		last := values[len(values)-1]
+		scheme = last[strings.LastIndexByte(last, ',')+1:]
+	}
+	if scheme == "" {
+		scheme = h.Get(HeaderXForwardedProtocol)
+	}
+	if scheme == "" && strings.EqualFold(h.Get(HeaderXForwardedSsl), "on") {
+		scheme = "https"
+	}
+	if scheme == "" {
+		scheme = h.Get(HeaderXUrlScheme)
+	}
+
+	if strings.EqualFold(strings.TrimSpace(scheme), "https") {
+		return "https"
+	}
+	return "http"
+}
+
+// forwardedProto walks the Forwarded elements from the nearest hop, as
+// forwardedClient does, and returns the last proto seen before reaching an
+// element whose "for" is not a trusted proxy.
+func (c *Context) forwardedProto(values []string) string {
+	elements := forwardedElements(values)
+
+	var proto string
+	for i := len(elements) - 1; i >= 0; i-- {
+		if p := forwardedParam(elements[i], "proto"); p != "" {
+			proto = p
+		}
+		addr, ok := hopAddr(forwardedParam(elements[i], "for"))
+		if !ok || !c.router.trustedProxy(addr) {
+			break
+		}
+	}
+	return proto
+}
+
+func (c *Context) trustsPeer(peer netip.Addr) bool {
+	return c.router != nil && c.router.trustedProxy(peer)
+}
+
+func peerAddr(remoteAddr string) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// hopAddr parses a forwarded address, which may carry a port and, for IPv6
+// in Forwarded, brackets.
+func hopAddr(hop string) (netip.Addr, bool) {
+	hop = strings.TrimSpace(hop)
+	if addr, err := netip.ParseAddr(hop); err == nil {
+		return addr.Unmap(), true
+	}
+	if ap, err := netip.ParseAddrPort(hop); err == nil {
+		return ap.Addr().Unmap(), true
+	}
+	if strings.HasPrefix(hop, "[") && strings.HasSuffix(hop, "]") {
+		if addr, err := netip.ParseAddr(hop[1 : len(hop)-1]); err == nil {
+			return addr.Unmap(), true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// forwardedParams collects the values of param from every element of the
+// RFC 7239 Forwarded header lines, in order.
+func forwardedParams(values []string, param string) []string {
+	var params []string
+	for _, element := range forwardedElements(values) {
+		if v := forwardedParam(element, param); v != "" {
+			params = append(params, v)
+		}
+	}
+	return params
+}
+
+func forwardedElements(values []string) []string {
+	var elements []string
+	for _, v := range values {
+		elements = append(elements, strings.Split(v, ",")...)
+	}
+	return elements
+}
+
+func forwardedParam(element, param string) string {
+	for pair := range strings.SplitSeq(element, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && strings.EqualFold(key, param) {
+			return strings.Trim(value, `"`)
+		}
+	}
+	return ""
+}
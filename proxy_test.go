@@ -0,0 +1,46 @@
+package teta
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContextScheme(t *testing.T) {
+	tests := []struct {
+		name   string
+		remote string
+		header string
+		value  string
+		want   string
+	}{
+		{"untrusted peer", "203.0.113.9:1234", HeaderXForwardedProto, "https", "http"},
+		{"x-forwarded-proto", "10.0.0.1:1234", HeaderXForwardedProto, "https", "https"},
+		{"x-forwarded-proto spoofed", "10.0.0.1:1234", HeaderXForwardedProto, "https, http", "http"},
+		{"forwarded", "10.0.0.1:1234", HeaderForwarded, "for=1.2.3.4;proto=https", "https"},
+		{"forwarded spoofed", "10.0.0.1:1234", HeaderForwarded, "proto=https, for=1.2.3.4;proto=http", "http"},
+		{"forwarded proxy chain", "10.0.0.1:1234", HeaderForwarded, "for=1.2.3.4;proto=https, for=10.0.0.2;proto=http", "https"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New()
+			if err := r.SetTrustedProxies("10.0.0.0/8"); err != nil {
+				t.Fatal(err)
+			}
+
+			var got string
+			r.Get("/", func(c *Context) error {
+				got = c.Scheme()
+				return nil
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remote
+			req.Header.Set(tt.header, tt.value)
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Fatalf("Scheme() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
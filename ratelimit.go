@@ -0,0 +1,334 @@
+package teta
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitStore counts requests per key. The in-memory stores implement
+// a token bucket and a sliding window; shared backends such as Redis
+// implement the same decision atomically on their side.
+type RateLimitStore interface {
+	// Allow consumes one request for key.
+	Allow(ctx context.Context, key string) (RateLimitResult, error)
+}
+
+type RateLimitResult struct {
+	Allowed bool
+	// Limit requests are allowed per Window.
+	Limit  int
+	Window time.Duration
+	// Remaining requests before the limit is hit.
+	Remaining int
+	// Reset is the time until the quota is fully available again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, when
+	// Allowed is false.
+	RetryAfter time.Duration
+}
+
+type RateLimitConfig struct {
+	Skipper Skipper
+	Store   RateLimitStore
+	// KeyFunc identifies the client. Defaults to RateLimitByIP.
+	KeyFunc func(c *Context) (string, error)
+	// KeyPrefix separates the counters of groups sharing a Store.
+	KeyPrefix string
+}
+
+var ErrRateLimited = NewHTTPError(http.StatusTooManyRequests, "rate limit exceeded")
+
+func RateLimit(store RateLimitStore) Middleware {
+	return RateLimitWithConfig(RateLimitConfig{Store: store})
+}
+
+// RateLimitWithConfig rejects clients over their quota with a 429 and a
+// Retry-After header, and reports the quota in RateLimit-* headers. Limits
+// apply to the group the middleware is used on:
+//
+//	r.Route("/search", func(g *teta.RouterGroup) {
+//		g.Use(teta.RateLimit(teta.NewSlidingWindowStore(30, time.Minute)))
+//		g.Get("", search)
+//	})
+func RateLimitWithConfig(config RateLimitConfig) Middleware {
+	if config.Store == nil {
+		panic("teta: rate limit requires a Store")
+	}
+	if config.Skipper == nil {
+		config.Skipper = DefaultSkipper
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = RateLimitByIP
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			key, err := config.KeyFunc(c)
+			if err != nil {
+				return err
+			}
+
+			res, err := config.Store.Allow(c.Context(), config.KeyPrefix+key)
+			if err != nil {
+				return err
+			}
+
+			h := c.Writer.Header()
+			h.Set(HeaderRateLimitLimit, strconv.Itoa(res.Limit))
+			h.Set(HeaderRateLimitRemaining, strconv.Itoa(res.Remaining))
+			h.Set(HeaderRateLimitReset, ceilSeconds(res.Reset))
+			h.Set(HeaderRateLimitPolicy, strconv.Itoa(res.Limit)+";w="+ceilSeconds(res.Window))
+
+			if !res.Allowed {
+				h.Set(HeaderRetryAfter, ceilSeconds(max(res.RetryAfter, time.Second)))
+				return ErrRateLimited
+			}
+			return next(c)
+		}
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+func RateLimitByIP(c *Context) (string, error) {
+	return "ip:" + c.RealIP(), nil
+}
+
+// RateLimitByUser keys on UserKey, set by the auth middleware, and falls
+// back to the client IP for anonymous requests.
+func RateLimitByUser(c *Context) (string, error) {
+	if user, _ := UserKey.Get(c); user != "" {
+		return "user:" + user, nil
+	}
+	return RateLimitByIP(c)
+}
+
+// RateLimitByAPIKey keys on the API key found through lookup, in the
+// APIKeyConfig.KeyLookup format, once the auth middleware has validated it;
+// made-up keys would otherwise each get a fresh quota. The key is hashed so
+// the secret is not kept in the store. Other requests are keyed on the
+// client IP.
+func RateLimitByAPIKey(lookup string) func(c *Context) (string, error) {
+	lookups := parseValueLookup(lookup)
+
+	return func(c *Context) (string, error) {
+		if _, ok := PrincipalKey.Get(c); !ok {
+			return RateLimitByIP(c)
+		}
+		for _, l := range lookups {
+			if key := l(c); key != "" {
+				sum := sha256.Sum256([]byte(key))
+				return "key:" + base64.RawURLEncoding.EncodeToString(sum[:16]), nil
+			}
+		}
+		return RateLimitByIP(c)
+	}
+}
+
+// TokenBucketStore refills limit tokens per period, up to burst, so short
+// bursts are absorbed while the average rate stays bounded.
+type TokenBucketStore struct {
+	limit  int
+	period time.Duration
+	burst  int
+	rate   float64
+	states rateLimitMap[tokenBucket]
+	now    func() time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucketStore allows limit requests per period with bursts of up
+// to burst requests. A burst below 1 defaults to limit.
+func NewTokenBucketStore(limit int, period time.Duration, burst int) *TokenBucketStore {
+	if burst < 1 {
+		burst = limit
+	}
+
+	s := &TokenBucketStore{
+		limit:  limit,
+		period: period,
+		burst:  burst,
+		rate:   float64(limit) / period.Seconds(),
+		now:    time.Now,
+	}
+	s.states.ttl = time.Duration(float64(burst) / s.rate * float64(time.Second))
+	return s
+}
+
+func (s *TokenBucketStore) Allow(_ context.Context, key string) (RateLimitResult, error) {
+	// A full bucket allows burst requests at once, refilled over the time
+	// it takes to earn burst tokens.
+	now := s.now()
+	res := RateLimitResult{Limit: s.burst, Window: s.duration(float64(s.burst))}
+
+	s.states.update(key, now, func(b *tokenBucket, fresh bool) {
+		if fresh {
+			b.tokens = float64(s.burst)
+		} else {
+			b.tokens = min(float64(s.burst), b.tokens+now.Sub(b.last).Seconds()*s.rate)
+		}
+		b.last = now
+
+		if b.tokens >= 1 {
+			b.tokens--
+			res.Allowed = true
+		} else {
+			res.RetryAfter = s.duration(1 - b.tokens)
+		}
+		res.Remaining = int(b.tokens)
+		res.Reset = s.duration(float64(s.burst) - b.tokens)
+	})
+
+	return res, nil
+}
+
+func (s *TokenBucketStore) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / s.rate * float64(time.Second))
+}
+
+// SlidingWindowStore allows limit requests in any window. It weights the
+// count of the previous fixed window by its overlap with the sliding one,
+// which needs two counters per key instead of a log of requests.
+type SlidingWindowStore struct {
+	limit  int
+	window time.Duration
+	states rateLimitMap[slidingWindow]
+	now    func() time.Time
+}
+
+type slidingWindow struct {
+	start    time.Time
+	previous int
+	current  int
+}
+
+func NewSlidingWindowStore(limit int, window time.Duration) *SlidingWindowStore {
+	s := &SlidingWindowStore{
+		limit:  limit,
+		window: window,
+		now:    time.Now,
+	}
+	s.states.ttl = 2 * window
+	return s
+}
+
+func (s *SlidingWindowStore) Allow(_ context.Context, key string) (RateLimitResult, error) {
+	now := s.now()
+	res := RateLimitResult{Limit: s.limit, Window: s.window}
+
+	s.states.update(key, now, func(w *slidingWindow, fresh bool) {
+		start := now.Truncate(s.window)
+		switch {
+		case fresh || start.Sub(w.start) > s.window:
+			w.previous, w.current = 0, 0
+		case start.After(w.start):
+			w.previous, w.current = w.current, 0
+		}
+		w.start = start
+
+		elapsed := now.Sub(start)
+		weight := 1 - float64(elapsed)/float64(s.window)
+		count := float64(w.previous)*weight + float64(w.current)
+
+		if count+1 <= float64(s.limit) {
+			w.current++
+			count++
+			res.Allowed = true
+		} else {
+			res.RetryAfter = s.retryAfter(w, elapsed)
+		}
+		res.Remaining = max(0, s.limit-int(math.Ceil(count)))
+		res.Reset = s.window - elapsed
+	})
+
+	return res, nil
+}
+
+// retryAfter finds when the weighted count leaves room for one request:
+// within this window as the previous one fades out, or in the next window
+// as this one does.
+func (s *SlidingWindowStore) retryAfter(w *slidingWindow, elapsed time.Duration) time.Duration {
+	window := float64(s.window)
+	room := float64(s.limit - 1)
+
+	if free := room - float64(w.current); free >= 0 && w.previous > 0 {
+		return time.Duration(window*(1-free/float64(w.previous))) - elapsed
+	}
+	if w.current == 0 {
+		return s.window - elapsed
+	}
+	return s.window - elapsed + time.Duration(max(0, window*(1-room/float64(w.current))))
+}
+
+const rateLimitShards = 64
+
+// rateLimitMap holds per-key state in shards with their own lock. Entries
+// idle for longer than ttl are swept lazily as the shard is used.
+type rateLimitMap[V any] struct {
+	ttl    time.Duration
+	shards [rateLimitShards]rateLimitShard[V]
+}
+
+type rateLimitShard[V any] struct {
+	mu      sync.Mutex
+	entries map[string]*rateLimitEntry[V]
+	swept   time.Time
+}
+
+type rateLimitEntry[V any] struct {
+	state V
+	seen  time.Time
+}
+
+func (m *rateLimitMap[V]) update(key string, now time.Time, fn func(state *V, fresh bool)) {
+	shard := &m.shards[shardIndex(key)]
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if shard.entries == nil {
+		shard.entries = make(map[string]*rateLimitEntry[V])
+	}
+	if now.Sub(shard.swept) > m.ttl {
+		for k, e := range shard.entries {
+			if now.Sub(e.seen) > m.ttl {
+				delete(shard.entries, k)
+			}
+		}
+		shard.swept = now
+	}
+
+	e, ok := shard.entries[key]
+	if !ok {
+		e = &rateLimitEntry[V]{}
+		shard.entries[key] = e
+	}
+	fn(&e.state, !ok)
+	e.seen = now
+}
+
+// shardIndex hashes key with FNV-1a.
+func shardIndex(key string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return h % rateLimitShards
+}
@@ -0,0 +1,55 @@
+package teta
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketReportsBurst(t *testing.T) {
+	s := NewTokenBucketStore(10, time.Minute, 5)
+
+	res, err := s.Allow(context.Background(), "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Limit != 5 || res.Remaining != 4 {
+		t.Fatalf("limit = %d, remaining = %d; want 5, 4", res.Limit, res.Remaining)
+	}
+	if res.Window != 30*time.Second {
+		t.Fatalf("window = %v, want 30s", res.Window)
+	}
+}
+
+func TestRateLimitByAPIKey(t *testing.T) {
+	keyFunc := RateLimitByAPIKey(DefaultAPIKeyConfig.KeyLookup)
+
+	var keys []string
+	handler := func(c *Context) error {
+		key, err := keyFunc(c)
+		keys = append(keys, key)
+		return err
+	}
+
+	r := New()
+	r.Get("/anonymous", handler)
+	r.With(APIKey(func(c *Context, key string) (any, error) {
+		return "svc", nil
+	})).Get("/authenticated", handler)
+
+	for _, path := range []string{"/anonymous", "/authenticated"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-API-Key", "s3cret")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if !strings.HasPrefix(keys[0], "ip:") {
+		t.Errorf("unvalidated key = %q, want the client IP", keys[0])
+	}
+	if !strings.HasPrefix(keys[1], "key:") || strings.Contains(keys[1], "s3cret") {
+		t.Errorf("validated key = %q, want a hashed API key", keys[1])
+	}
+}
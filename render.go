@@ -0,0 +1,261 @@
+package teta
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"sync"
+)
+
+var ErrRendererNotRegistered = errors.New("renderer not registered")
+
+// Renderer renders the named template with data.
+type Renderer interface {
+	Render(w io.Writer, name string, data any, c *Context) error
+}
+
+// Render writes the named template as an HTML response using the router's
+// Renderer. Output is buffered, so template errors still reach the error
+// handler.
+func (c *Context) Render(status int, name string, data any) error {
+	if c.router == nil || c.router.renderer == nil {
+		return ErrRendererNotRegistered
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if err := c.router.renderer.Render(buf, name, data, c); err != nil {
+		return err
+	}
+
+	return c.Blob(status, MIMETextHTMLCharsetUTF8, buf.Bytes())
+}
+
+type HTMLRendererConfig struct {
+	// Filesystem holds the templates.
+	Filesystem fs.FS
+	// Layouts and Partials are glob patterns of templates shared by every
+	// page, for example "layouts/*.html" and "partials/*.html".
+	Layouts  []string
+	Partials []string
+	// Layout is the template executed for every page; pages then fill its
+	// blocks with {{define}}. When empty the page itself is executed.
+	Layout string
+	// Extension is appended to page names without one. Defaults to ".html".
+	Extension string
+	Funcs     template.FuncMap
+	// Reload parses the templates again on every render, for development.
+	Reload bool
+}
+
+// HTMLRenderer is the default html/template Renderer. Every page is parsed
+// together with the layouts and partials into its own template set, so pages
+// can define the same blocks without clashing.
+type HTMLRenderer struct {
+	config HTMLRendererConfig
+	router *Router
+
+	mu    sync.RWMutex
+	base  *template.Template
+	pages map[string]*htmlPage
+}
+
+// htmlPage keeps the parsed page as a prototype that is never executed, and
+// a pool of executable clones. Each clone is escaped once, on its first
+// render, and its request funcs read the Context it is rendering for.
+type htmlPage struct {
+	proto     *template.Template
+	instances sync.Pool
+}
+
+type htmlPageInstance struct {
+	tmpl *template.Template
+	c    *Context
+}
+
+func newHTMLPage(proto *template.Template) *htmlPage {
+	return &htmlPage{proto: proto}
+}
+
+func (p *htmlPage) get() (*htmlPageInstance, error) {
+	if inst, ok := p.instances.Get().(*htmlPageInstance); ok {
+		return inst, nil
+	}
+
+	tmpl, err := p.proto.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	inst := &htmlPageInstance{tmpl: tmpl}
+	tmpl.Funcs(template.FuncMap{
+		"cspNonce": func() string {
+			if inst.c == nil {
+				return ""
+			}
+			return inst.c.CSPNonce()
+		},
+		"csrfToken": func() string {
+			if inst.c == nil {
+				return ""
+			}
+			return inst.c.CSRFToken()
+		},
+	})
+	return inst, nil
+}
+
+func (p *htmlPage) put(inst *htmlPageInstance) {
+	inst.c = nil
+	p.instances.Put(inst)
+}
+
+// NewHTMLRenderer parses the layouts and partials of config. The "url"
+// template func resolves named routes once the renderer is set on a Router;
+// "cspNonce" and "csrfToken" return Context.CSPNonce and Context.CSRFToken
+// of the request being rendered.
+func NewHTMLRenderer(config HTMLRendererConfig) (*HTMLRenderer, error) {
+	if config.Filesystem == nil {
+		return nil, errors.New("HTMLRendererConfig.Filesystem is required")
+	}
+	if config.Extension == "" {
+		config.Extension = ".html"
+	}
+
+	h := &HTMLRenderer{config: config}
+
+	base, err := h.parseBase()
+	if err != nil {
+		return nil, err
+	}
+	h.base = base
+	h.pages = make(map[string]*htmlPage)
+
+	return h, nil
+}
+
+func (h *HTMLRenderer) bindRouter(router *Router) {
+	h.router = router
+}
+
+func (h *HTMLRenderer) Render(w io.Writer, name string, data any, c *Context) error {
+	page, err := h.page(name)
+	if err != nil {
+		return err
+	}
+
+	entry := h.config.Layout
+	if entry == "" {
+		entry = page.proto.Name()
+	}
+
+	inst, err := page.get()
+	if err != nil {
+		return err
+	}
+	defer page.put(inst)
+
+	inst.c = c
+	return inst.tmpl.ExecuteTemplate(w, entry, data)
+}
+
+func (h *HTMLRenderer) page(name string) (*htmlPage, error) {
+	if path.Ext(name) == "" {
+		name += h.config.Extension
+	}
+
+	if h.config.Reload {
+		base, err := h.parseBase()
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := h.parsePage(base, name)
+		if err != nil {
+			return nil, err
+		}
+		return newHTMLPage(tmpl), nil
+	}
+
+	h.mu.RLock()
+	page, ok := h.pages[name]
+	h.mu.RUnlock()
+	if ok {
+		return page, nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if page, ok := h.pages[name]; ok {
+		return page, nil
+	}
+
+	tmpl, err := h.parsePage(h.base, name)
+	if err != nil {
+		return nil, err
+	}
+	page = newHTMLPage(tmpl)
+	h.pages[name] = page
+
+	return page, nil
+}
+
+func (h *HTMLRenderer) parseBase() (*template.Template, error) {
+	base := template.New("").Funcs(h.funcs())
+
+	for _, pattern := range append(append([]string(nil), h.config.Layouts...), h.config.Partials...) {
+		matches, err := fs.Glob(h.config.Filesystem, pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			continue
+		}
+		if base, err = base.ParseFS(h.config.Filesystem, matches...); err != nil {
+			return nil, err
+		}
+	}
+
+	return base, nil
+}
+
+func (h *HTMLRenderer) parsePage(base *template.Template, name string) (*template.Template, error) {
+	if !fs.ValidPath(name) {
+		return nil, fmt.Errorf("invalid template name %q", name)
+	}
+
+	content, err := fs.ReadFile(h.config.Filesystem, name)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := base.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpl.New(name).Parse(string(content))
+}
+
+func (h *HTMLRenderer) funcs() template.FuncMap {
+	funcs := template.FuncMap{
+		"url": func(name string, params ...any) (string, error) {
+			if h.router == nil {
+				return "", errors.New("renderer is not set on a router")
+			}
+			return h.router.Reverse(name, params...)
+		},
+		// Bound to the request by htmlPage.
+		"cspNonce":  func() string { return "" },
+		"csrfToken": func() string { return "" },
+	}
+	for name, fn := range h.config.Funcs {
+		funcs[name] = fn
+	}
+
+	return funcs
+}
@@ -0,0 +1,102 @@
+package teta
+
+import (
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestHTMLRendererRequestFuncs(t *testing.T) {
+	renderer, err := NewHTMLRenderer(HTMLRendererConfig{
+		Filesystem: fstest.MapFS{
+			"form.html": {Data: []byte(`<script nonce="{{cspNonce}}"></script><input name="_csrf" value="{{csrfToken}}">`)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := New()
+	r.SetRenderer(renderer)
+	secure := DefaultSecureConfig
+	secure.ContentSecurityPolicy = "script-src 'nonce-" + CSPNoncePlaceholder + "'"
+	r.Use(SecureWithConfig(secure), CSRF())
+
+	var nonce, token string
+	r.Get("/form", func(c *Context) error {
+		nonce, token = c.CSPNonce(), c.CSRFToken()
+		return c.Render(http.StatusOK, "form", nil)
+	})
+
+	for range 2 {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/form", nil))
+
+		body := html.UnescapeString(w.Body.String())
+		if nonce == "" || !strings.Contains(body, `nonce="`+nonce+`"`) {
+			t.Fatalf("body %q does not carry the nonce %q", body, nonce)
+		}
+		if token == "" || !strings.Contains(body, `value="`+token+`"`) {
+			t.Fatalf("body %q does not carry the token %q", body, token)
+		}
+	}
+}
+
+func TestSecureWithoutCSPLeavesStoreUnset(t *testing.T) {
+	r := New()
+	r.Use(Secure())
+
+	allocated := true
+	r.Get("/", func(c *Context) error {
+		allocated = c.store != nil
+		return nil
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if allocated {
+		t.Fatal("Secure without a policy allocated the value store")
+	}
+}
+
+func TestHTMLRendererWithoutContext(t *testing.T) {
+	renderer, err := NewHTMLRenderer(HTMLRendererConfig{
+		Filesystem: fstest.MapFS{
+			"page.html": {Data: []byte(`<p nonce="{{cspNonce}}">{{.}}</p>`)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := renderer.Render(&b, "page", "mail", nil); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != `<p nonce="">mail</p>` {
+		t.Fatalf("body = %q", b.String())
+	}
+
+	r := New()
+	r.SetRenderer(renderer)
+	r.Get("/", func(c *Context) error {
+		return c.Render(http.StatusOK, "page", "web")
+	})
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "web") {
+				t.Errorf("status = %d, body = %q", w.Code, w.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,89 @@
+package teta
+
+type RequestIDConfig struct {
+	Skipper Skipper
+	// Generator creates new IDs. Defaults to NewUUIDv4.
+	Generator func() string
+	// Header is read when TrustIncoming is set and always written to the
+	// response. Defaults to X-Request-Id.
+	Header string
+	// TrustIncoming reuses a well-formed ID sent by the client or an upstream
+	// proxy in Header or X-Correlation-Id instead of generating one.
+	TrustIncoming bool
+}
+
+var DefaultRequestIDConfig = RequestIDConfig{
+	Skipper:   DefaultSkipper,
+	Generator: NewUUIDv4,
+	Header:    HeaderXRequestID,
+}
+
+// RequestIDKey holds the request ID set by the RequestID middleware.
+var RequestIDKey = NewKey[string]("request_id")
+
+const maxRequestIDLength = 128
+
+func RequestID() Middleware {
+	return RequestIDWithConfig(DefaultRequestIDConfig)
+}
+
+// RequestIDWithConfig accepts or generates a request ID, echoes it in the
+// response and stores it on the Context, where the request logger picks it
+// up for every line logged for the request.
+func RequestIDWithConfig(config RequestIDConfig) Middleware {
+	if config.Skipper == nil {
+		config.Skipper = DefaultRequestIDConfig.Skipper
+	}
+	if config.Generator == nil {
+		config.Generator = DefaultRequestIDConfig.Generator
+	}
+	if config.Header == "" {
+		config.Header = DefaultRequestIDConfig.Header
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			id := ""
+			if config.TrustIncoming {
+				id = c.Request.Header.Get(config.Header)
+				if id == "" {
+					id = c.Request.Header.Get(HeaderXCorrelationID)
+				}
+				if !validRequestID(id) {
+					id = ""
+				}
+			}
+			if id == "" {
+				id = config.Generator()
+			}
+
+			c.Writer.Header().Set(config.Header, id)
+			RequestIDKey.Set(c, id)
+
+			return next(c)
+		}
+	}
+}
+
+func (c *Context) RequestID() string {
+	id, _ := RequestIDKey.Get(c)
+	return id
+}
+
+// validRequestID guards the logs against oversized or forged values: only
+// printable ASCII without spaces is accepted from the outside.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
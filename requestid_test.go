@@ -0,0 +1,107 @@
+package teta
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewUUID(t *testing.T) {
+	for _, tt := range []struct {
+		gen     func() string
+		version byte
+	}{
+		{NewUUIDv4, 4},
+		{NewUUIDv7, 7},
+	} {
+		for range 100 {
+			id := tt.gen()
+			b, err := hex.DecodeString(strings.ReplaceAll(id, "-", ""))
+			if err != nil || len(id) != 36 || len(b) != 16 {
+				t.Fatalf("v%d: malformed UUID %q", tt.version, id)
+			}
+			for _, i := range []int{8, 13, 18, 23} {
+				if id[i] != '-' {
+					t.Fatalf("v%d: %q has no hyphen at %d", tt.version, id, i)
+				}
+			}
+			if v := b[6] >> 4; v != tt.version {
+				t.Fatalf("v%d: %q has version %d", tt.version, id, v)
+			}
+			if b[8]&0xc0 != 0x80 {
+				t.Fatalf("v%d: %q does not have the RFC 9562 variant", tt.version, id)
+			}
+		}
+	}
+}
+
+func TestNewULID(t *testing.T) {
+	prev := ""
+	for range 100 {
+		id := NewULID()
+		if len(id) != 26 {
+			t.Fatalf("len(%q) = %d, want 26", id, len(id))
+		}
+		if strings.Trim(id, crockfordAlphabet) != "" {
+			t.Fatalf("%q is not Crockford base32", id)
+		}
+		// The first character holds only the top 3 bits of the timestamp.
+		if id[0] > '7' {
+			t.Fatalf("%q overflows 128 bits", id)
+		}
+		if id[:10] < prev {
+			t.Fatalf("timestamp %q sorts before %q", id[:10], prev)
+		}
+		prev = id[:10]
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	generated := func() string { return "generated" }
+
+	tests := []struct {
+		name   string
+		trust  bool
+		header string
+		value  string
+		want   string
+	}{
+		{"generate", false, "", "", "generated"},
+		{"untrusted", false, HeaderXRequestID, "abc-123", "generated"},
+		{"trusted", true, HeaderXRequestID, "abc-123", "abc-123"},
+		{"correlation id", true, HeaderXCorrelationID, "corr-9", "corr-9"},
+		{"oversized", true, HeaderXRequestID, strings.Repeat("a", maxRequestIDLength+1), "generated"},
+		{"max length", true, HeaderXRequestID, strings.Repeat("a", maxRequestIDLength), strings.Repeat("a", maxRequestIDLength)},
+		{"space", true, HeaderXRequestID, "abc 123", "generated"},
+		{"control", true, HeaderXRequestID, "abc\x7f", "generated"},
+		{"non-ascii", true, HeaderXRequestID, "abcé", "generated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New()
+			r.Use(RequestIDWithConfig(RequestIDConfig{Generator: generated, TrustIncoming: tt.trust}))
+
+			var id string
+			r.Get("/", func(c *Context) error {
+				id = c.RequestID()
+				return c.NoContent(http.StatusOK)
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if id != tt.want {
+				t.Errorf("RequestID() = %q, want %q", id, tt.want)
+			}
+			if got := w.Header().Get(HeaderXRequestID); got != tt.want {
+				t.Errorf("%s = %q, want %q", HeaderXRequestID, got, tt.want)
+			}
+		})
+	}
+}
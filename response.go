@@ -0,0 +1,94 @@
+package teta
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// Response wraps the http.ResponseWriter of a request and records what was
+// sent. It forwards Flush and Hijack and supports http.ResponseController
+// through Unwrap.
+type Response struct {
+	Writer    http.ResponseWriter
+	Status    int
+	Size      int64
+	Committed bool
+	hijacked  bool
+}
+
+func (r *Response) reset(w http.ResponseWriter) {
+	r.Writer = w
+	r.Status = http.StatusOK
+	r.Size = 0
+	r.Committed = false
+	r.hijacked = false
+}
+
+func (r *Response) Header() http.Header {
+	return r.Writer.Header()
+}
+
+// WriteHeader sends the status code once. Informational codes other than
+// 101 may be sent any number of times before the final one; later calls are
+// ignored instead of producing a "superfluous WriteHeader" warning.
+func (r *Response) WriteHeader(code int) {
+	if r.Committed || r.hijacked {
+		return
+	}
+
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		r.Writer.WriteHeader(code)
+		return
+	}
+
+	r.Status = code
+	r.Committed = true
+	r.Writer.WriteHeader(code)
+}
+
+func (r *Response) Write(b []byte) (int, error) {
+	if r.hijacked {
+		return 0, http.ErrHijacked
+	}
+	if !r.Committed {
+		r.WriteHeader(http.StatusOK)
+	}
+
+	n, err := r.Writer.Write(b)
+	r.Size += int64(n)
+	return n, err
+}
+
+func (r *Response) Flush() {
+	r.FlushError()
+}
+
+func (r *Response) FlushError() error {
+	if r.hijacked {
+		return http.ErrHijacked
+	}
+	if !r.Committed {
+		r.WriteHeader(http.StatusOK)
+	}
+	return http.NewResponseController(r.Writer).Flush()
+}
+
+func (r *Response) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.Writer).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r.hijacked = true
+	r.Committed = true
+	return conn, rw, nil
+}
+
+func (r *Response) Hijacked() bool {
+	return r.hijacked
+}
+
+func (r *Response) Unwrap() http.ResponseWriter {
+	return r.Writer
+}
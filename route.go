@@ -0,0 +1,93 @@
+package teta
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type Route struct {
+	Method string
+	Path   string
+	name   string
+	router *Router
+}
+
+// Name registers the route under name for reverse routing.
+func (r *Route) Name(name string) *Route {
+	r.name = name
+	if r.router != nil {
+		r.router.addNamedRoute(r)
+	}
+	return r
+}
+
+func (r *Route) RouteName() string {
+	return r.name
+}
+
+func (t *Router) addNamedRoute(r *Route) {
+	t.routesMu.Lock()
+	defer t.routesMu.Unlock()
+
+	if t.routes == nil {
+		t.routes = make(map[string]*Route)
+	}
+	t.routes[r.name] = r
+}
+
+// Reverse builds the path of the named route, substituting the pattern
+// wildcards with params in order. "{name...}" wildcards keep slashes.
+func (t *Router) Reverse(name string, params ...any) (string, error) {
+	t.routesMu.RLock()
+	route, ok := t.routes[name]
+	t.routesMu.RUnlock()
+
+	if !ok {
+		return "", fmt.Errorf("route %q not found", name)
+	}
+
+	return reversePath(route.Path, params)
+}
+
+func reversePath(pattern string, params []any) (string, error) {
+	pattern = strings.TrimSuffix(pattern, "{$}")
+
+	var b strings.Builder
+	n := 0
+
+	for {
+		start := strings.IndexByte(pattern, '{')
+		if start < 0 {
+			b.WriteString(pattern)
+			break
+		}
+		end := strings.IndexByte(pattern[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("invalid route pattern %q", pattern)
+		}
+		end += start
+
+		if n >= len(params) {
+			return "", fmt.Errorf("missing parameter for %s", pattern[start:end+1])
+		}
+
+		value := fmt.Sprint(params[n])
+		if strings.HasSuffix(pattern[start:end], "...") {
+			value = (&url.URL{Path: value}).EscapedPath()
+		} else {
+			value = url.PathEscape(value)
+		}
+
+		b.WriteString(pattern[:start])
+		b.WriteString(value)
+		pattern = pattern[end+1:]
+		n++
+	}
+
+	if n != len(params) {
+		return "", fmt.Errorf("too many parameters: got %d, want %d", len(params), n)
+	}
+
+	return b.String(), nil
+}
@@ -10,6 +10,7 @@ import (
 
 type RouterGroup struct {
 	prefix           string
+	router           *Router
 	handler          *http.ServeMux
 	middlewares      []Middleware
 	parent           *RouterGroup
@@ -20,9 +21,10 @@ type RouterGroup struct {
 type HandlerFunc func(c *Context) error
 type Middleware func(next HandlerFunc) HandlerFunc
 
-func newRouteGroup(v Validator, her HTTPErrorHandler) *RouterGroup {
+func newRouteGroup(router *Router, v Validator, her HTTPErrorHandler) *RouterGroup {
 	return &RouterGroup{
 		prefix:           "/",
+		router:           router,
 		handler:          http.NewServeMux(),
 		middlewares:      nil,
 		parent:           nil,
@@ -34,9 +36,10 @@ func newRouteGroup(v Validator, her HTTPErrorHandler) *RouterGroup {
 func (rg *RouterGroup) next(handler HandlerFunc) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := NewContext(w, r, rg.validator)
+		ctx.setRouter(rg.router)
 		defer ctx.Release()
 
-		if err := rg.applyMiddleware(handler)(ctx); err != nil {
+		if err := rg.applyMiddleware(handler)(ctx); err != nil && !ctx.errHandled {
 			rg.httpErrorHandler(err, ctx)
 		}
 	})
@@ -55,12 +58,13 @@ func (rg *RouterGroup) applyMiddleware(handler HandlerFunc) HandlerFunc {
 }
 
 func (rg *RouterGroup) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	rg.handler.ServeHTTP(w, r)
+	rg.router.ServeHTTP(w, r)
 }
 
 func (rg *RouterGroup) Route(pattern string, fn func(r *RouterGroup)) {
 	newGroup := &RouterGroup{
 		prefix:           path.Join(rg.prefix, pattern),
+		router:           rg.router,
 		handler:          rg.handler,
 		middlewares:      rg.middlewares,
 		parent:           rg,
@@ -73,6 +77,7 @@ func (rg *RouterGroup) Route(pattern string, fn func(r *RouterGroup)) {
 func (rg *RouterGroup) Group(fn func(r *RouterGroup)) {
 	newGroup := &RouterGroup{
 		prefix:           rg.prefix,
+		router:           rg.router,
 		handler:          rg.handler,
 		middlewares:      rg.middlewares,
 		parent:           rg,
@@ -89,6 +94,7 @@ func (rg *RouterGroup) Use(middleware ...Middleware) {
 func (rg *RouterGroup) With(middleware ...Middleware) *RouterGroup {
 	return &RouterGroup{
 		prefix:           rg.prefix,
+		router:           rg.router,
 		handler:          rg.handler,
 		middlewares:      append(rg.middlewares, middleware...),
 		parent:           rg,
@@ -97,11 +103,26 @@ func (rg *RouterGroup) With(middleware ...Middleware) *RouterGroup {
 	}
 }
 
-func (rg *RouterGroup) Handle(pattern string, handler HandlerFunc) {
+// Handle registers handler for a ServeMux pattern relative to the group.
+// Paths without an Options or Any route still answer OPTIONS, see
+// Router.ServeHTTP.
+func (rg *RouterGroup) Handle(pattern string, handler HandlerFunc) *Route {
 	method, pathPattern := parsePattern(pattern)
-	fullPath := path.Join(method, rg.prefix, pathPattern)
+	method = strings.TrimSpace(method)
+	fullPath := path.Join(rg.prefix, pathPattern)
+
+	if method == "" {
+		rg.handler.Handle(fullPath, rg.next(handler))
+	} else {
+		rg.handler.Handle(method+" "+fullPath, rg.next(handler))
+		rg.router.addPreflight(rg, method, method+" "+fullPath)
+	}
 
-	rg.handler.Handle(fullPath, rg.next(handler))
+	return &Route{
+		Method: method,
+		Path:   fullPath,
+		router: rg.router,
+	}
 }
 
 func parsePattern(pattern string) (method, path string) {
@@ -119,46 +140,46 @@ func concatPath(method, pattern string) string {
 	return b.String()
 }
 
-func (rg *RouterGroup) Add(method, pattern string, handler HandlerFunc) {
-	rg.Handle(concatPath(method, pattern), handler)
+func (rg *RouterGroup) Add(method, pattern string, handler HandlerFunc) *Route {
+	return rg.Handle(concatPath(method, pattern), handler)
 }
 
-func (rg *RouterGroup) Get(pattern string, handler HandlerFunc) {
-	rg.Add(http.MethodGet, pattern, handler)
+func (rg *RouterGroup) Get(pattern string, handler HandlerFunc) *Route {
+	return rg.Add(http.MethodGet, pattern, handler)
 }
 
-func (rg *RouterGroup) Post(pattern string, handler HandlerFunc) {
-	rg.Add(http.MethodPost, pattern, handler)
+func (rg *RouterGroup) Post(pattern string, handler HandlerFunc) *Route {
+	return rg.Add(http.MethodPost, pattern, handler)
 }
 
-func (rg *RouterGroup) Put(pattern string, handler HandlerFunc) {
-	rg.Add(http.MethodPut, pattern, handler)
+func (rg *RouterGroup) Put(pattern string, handler HandlerFunc) *Route {
+	return rg.Add(http.MethodPut, pattern, handler)
 }
 
-func (rg *RouterGroup) Delete(pattern string, handler HandlerFunc) {
-	rg.Add(http.MethodDelete, pattern, handler)
+func (rg *RouterGroup) Delete(pattern string, handler HandlerFunc) *Route {
+	return rg.Add(http.MethodDelete, pattern, handler)
 }
 
-func (rg *RouterGroup) Patch(pattern string, handler HandlerFunc) {
-	rg.Add(http.MethodPatch, pattern, handler)
+func (rg *RouterGroup) Patch(pattern string, handler HandlerFunc) *Route {
+	return rg.Add(http.MethodPatch, pattern, handler)
 }
 
-func (rg *RouterGroup) Head(pattern string, handler HandlerFunc) {
-	rg.Add(http.MethodHead, pattern, handler)
+func (rg *RouterGroup) Head(pattern string, handler HandlerFunc) *Route {
+	return rg.Add(http.MethodHead, pattern, handler)
 }
 
-func (rg *RouterGroup) Options(pattern string, handler HandlerFunc) {
-	rg.Add(http.MethodOptions, pattern, handler)
+func (rg *RouterGroup) Options(pattern string, handler HandlerFunc) *Route {
+	return rg.Add(http.MethodOptions, pattern, handler)
 }
 
-func (rg *RouterGroup) Connect(pattern string, handler HandlerFunc) {
-	rg.Add(http.MethodConnect, pattern, handler)
+func (rg *RouterGroup) Connect(pattern string, handler HandlerFunc) *Route {
+	return rg.Add(http.MethodConnect, pattern, handler)
 }
 
-func (rg *RouterGroup) Trace(pattern string, handler HandlerFunc) {
-	rg.Add(http.MethodTrace, pattern, handler)
+func (rg *RouterGroup) Trace(pattern string, handler HandlerFunc) *Route {
+	return rg.Add(http.MethodTrace, pattern, handler)
 }
 
-func (rg *RouterGroup) Any(pattern string, handler HandlerFunc) {
-	rg.Handle(pattern, handler)
+func (rg *RouterGroup) Any(pattern string, handler HandlerFunc) *Route {
+	return rg.Handle(pattern, handler)
 }
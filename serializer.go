@@ -0,0 +1,61 @@
+package teta
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// JSONSerializer encodes responses and decodes request bodies. Implement it
+// to plug in a faster codec or encoding/json/v2.
+type JSONSerializer interface {
+	Serialize(w io.Writer, v any, indent string) error
+	Deserialize(r io.Reader, v any) error
+}
+
+type DefaultJSONSerializer struct {
+	DisallowUnknownFields bool
+	UseNumber             bool
+}
+
+func (s *DefaultJSONSerializer) Serialize(w io.Writer, v any, indent string) error {
+	enc := json.NewEncoder(w)
+	if indent != "" {
+		enc.SetIndent("", indent)
+	}
+	return enc.Encode(v)
+}
+
+func (s *DefaultJSONSerializer) Deserialize(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	if s.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if s.UseNumber {
+		dec.UseNumber()
+	}
+	return dec.Decode(v)
+}
+
+var defaultJSONSerializer JSONSerializer = &DefaultJSONSerializer{}
+
+const maxPooledBufferSize = 64 << 10
+
+var bufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
@@ -0,0 +1,196 @@
+package teta
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrEventStreamClosed = errors.New("event stream closed")
+
+// Event is a single Server-Sent Event. Data of type string or []byte is sent
+// as is, anything else is encoded with the router's JSONSerializer.
+type Event struct {
+	ID    string
+	Event string
+	Data  any
+	Retry time.Duration
+}
+
+// EventStream writes Server-Sent Events to the response. Send and Comment
+// are safe for concurrent use. The stream is closed when the handler returns
+// at the latest, so goroutines still sending get ErrEventStreamClosed rather
+// than writing to a released Context.
+type EventStream struct {
+	w           http.ResponseWriter
+	serializer  JSONSerializer
+	rc          *http.ResponseController
+	ctx         context.Context
+	lastEventID string
+
+	mu     sync.Mutex
+	done   chan struct{}
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// SSE sends the event stream headers and returns the stream. The stream is
+// finished when the client goes away, which is reported by Done.
+func (c *Context) SSE() *EventStream {
+	header := c.Writer.Header()
+	header.Set(HeaderContentType, MIMETextEventStream)
+	header.Set(HeaderCacheControl, "no-cache")
+	header.Set(HeaderConnection, "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	header.Del(HeaderContentLength)
+
+	s := &EventStream{
+		w:           c.Writer,
+		serializer:  c.JSONSerializer(),
+		rc:          http.NewResponseController(c.Writer),
+		ctx:         c.Request.Context(),
+		lastEventID: c.Request.Header.Get(HeaderLastEventID),
+		done:        make(chan struct{}),
+	}
+
+	c.Writer.WriteHeader(http.StatusOK)
+	s.rc.Flush()
+
+	c.stream = s
+	return s
+}
+
+// LastEventID returns the Last-Event-ID sent by a reconnecting client.
+func (s *EventStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Done is closed when the client disconnects.
+func (s *EventStream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+func (s *EventStream) Send(e Event) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if e.ID != "" {
+		writeEventField(buf, "id", e.ID)
+	}
+	if e.Event != "" {
+		writeEventField(buf, "event", e.Event)
+	}
+	if e.Retry > 0 {
+		writeEventField(buf, "retry", strconv.FormatInt(e.Retry.Milliseconds(), 10))
+	}
+
+	switch data := e.Data.(type) {
+	case nil:
+	case string:
+		writeEventField(buf, "data", data)
+	case []byte:
+		writeEventField(buf, "data", string(data))
+	default:
+		encoded := getBuffer()
+		defer putBuffer(encoded)
+
+		if err := s.serializer.Serialize(encoded, data, ""); err != nil {
+			return err
+		}
+		writeEventField(buf, "data", strings.TrimSuffix(encoded.String(), "\n"))
+	}
+	buf.WriteByte('\n')
+
+	return s.write(buf.Bytes())
+}
+
+// Comment sends a comment line, which clients ignore. It is used for
+// heartbeats that keep intermediaries from closing an idle connection.
+func (s *EventStream) Comment(text string) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	for line := range strings.Lines(text) {
+		buf.WriteString(": ")
+		buf.WriteString(strings.TrimRight(line, "\r\n"))
+		buf.WriteByte('\n')
+	}
+	if text == "" {
+		buf.WriteString(":\n")
+	}
+	buf.WriteByte('\n')
+
+	return s.write(buf.Bytes())
+}
+
+// Heartbeat sends a comment every interval until the stream is closed or the
+// client disconnects.
+func (s *EventStream) Heartbeat(interval time.Duration) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Comment("heartbeat"); err != nil {
+					return
+				}
+			case <-s.done:
+				return
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the heartbeat and waits for it to finish. Nothing is written to
+// the response after Close returns.
+func (s *EventStream) Close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *EventStream) write(p []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrEventStreamClosed
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, err := s.w.Write(p); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func writeEventField(buf *bytes.Buffer, name, value string) {
+	value = strings.ReplaceAll(value, "\r\n", "\n")
+	value = strings.ReplaceAll(value, "\r", "\n")
+
+	for line := range strings.SplitSeq(value, "\n") {
+		buf.WriteString(name)
+		buf.WriteString(": ")
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+}
@@ -0,0 +1,29 @@
+package teta
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventStreamClosedWhenHandlerReturns(t *testing.T) {
+	r := New()
+
+	var stream *EventStream
+	r.Get("/events", func(c *Context) error {
+		stream = c.SSE()
+		stream.Heartbeat(time.Millisecond)
+		return stream.Send(Event{Event: "greeting", Data: "hello"})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/events", nil))
+
+	if !strings.Contains(w.Body.String(), "event: greeting\ndata: hello\n\n") {
+		t.Fatalf("body = %q", w.Body.String())
+	}
+	if err := stream.Send(Event{Data: "late"}); err != ErrEventStreamClosed {
+		t.Fatalf("Send after the handler returned: err = %v, want ErrEventStreamClosed", err)
+	}
+}
@@ -0,0 +1,226 @@
+package teta
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type StaticConfig struct {
+	// Filesystem is the root the files are served from.
+	Filesystem fs.FS
+	// Index is served for directory requests. Defaults to "index.html".
+	Index string
+	// Browse enables directory listings for directories without an index.
+	Browse bool
+	// SPA serves the root index for paths that do not exist, so client side
+	// routes of single page applications resolve.
+	SPA bool
+	// MaxAge sets Cache-Control max-age when positive.
+	MaxAge time.Duration
+}
+
+// Static serves the files of dir under prefix. Files are opened through an
+// os.Root, so symlinks cannot lead outside dir.
+func (rg *RouterGroup) Static(prefix, dir string) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		panic("teta: " + err.Error())
+	}
+	rg.StaticWithConfig(prefix, StaticConfig{Filesystem: root.FS()})
+}
+
+// StaticFS serves fsys, for example an embed.FS, under prefix.
+func (rg *RouterGroup) StaticFS(prefix string, fsys fs.FS) {
+	rg.StaticWithConfig(prefix, StaticConfig{Filesystem: fsys})
+}
+
+func (rg *RouterGroup) StaticWithConfig(prefix string, config StaticConfig) {
+	if config.Filesystem == nil {
+		panic("teta: StaticConfig.Filesystem is required")
+	}
+	if config.Index == "" {
+		config.Index = "index.html"
+	}
+
+	s := &staticHandler{config: config}
+	rg.Get(path.Join(prefix, "{path...}"), s.handle)
+}
+
+type staticHandler struct {
+	config StaticConfig
+	etags  sync.Map
+}
+
+func (s *staticHandler) handle(c *Context) error {
+	name, ok := cleanFSPath(c.Request.PathValue("path"))
+	if !ok {
+		return NewHTTPError(http.StatusNotFound, http.StatusText(http.StatusNotFound))
+	}
+
+	err := s.serve(c, name)
+	if s.config.SPA && errors.Is(err, fs.ErrNotExist) {
+		err = s.serve(c, s.config.Index)
+	}
+
+	return fsError(err)
+}
+
+func (s *staticHandler) serve(c *Context, name string) error {
+	fsys := s.config.Filesystem
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		// os.Root refuses paths escaping the directory with an error of
+		// its own; to the client they do not exist.
+		if !errors.Is(err, fs.ErrPermission) {
+			return fs.ErrNotExist
+		}
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		index := path.Join(name, s.config.Index)
+		if indexFile, err := fsys.Open(index); err == nil {
+			defer indexFile.Close()
+			if indexInfo, err := indexFile.Stat(); err == nil && !indexInfo.IsDir() {
+				return s.serveFile(c, index, indexFile, indexInfo)
+			}
+		}
+
+		if !s.config.Browse {
+			return fs.ErrNotExist
+		}
+		return listDirectory(c, fsys, name)
+	}
+
+	return s.serveFile(c, name, f, info)
+}
+
+func (s *staticHandler) serveFile(c *Context, name string, f fs.File, info fs.FileInfo) error {
+	if s.config.MaxAge > 0 {
+		c.Writer.Header().Set(HeaderCacheControl, "public, max-age="+strconv.Itoa(int(s.config.MaxAge.Seconds())))
+	}
+
+	// Embedded files have no modification time, so their ETag is derived
+	// from the content. It cannot change and is computed once per file.
+	if info.ModTime().IsZero() {
+		if etag, ok := s.etags.Load(name); ok {
+			c.Writer.Header().Set(HeaderETag, etag.(string))
+		}
+	}
+
+	return serveContent(c, f, info, &s.etags, name)
+}
+
+// serveContent writes f with http.ServeContent, which handles conditional
+// requests, Range/If-Range and content type detection. An ETag is set
+// unless the caller already did; contentETags caches content-based tags.
+func serveContent(c *Context, f fs.File, info fs.FileInfo, contentETags *sync.Map, key string) error {
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		content = bytes.NewReader(data)
+	}
+
+	header := c.Writer.Header()
+	if header.Get(HeaderETag) == "" {
+		if info.ModTime().IsZero() {
+			etag, err := contentETag(content)
+			if err != nil {
+				return err
+			}
+			if contentETags != nil {
+				contentETags.Store(key, etag)
+			}
+			header.Set(HeaderETag, etag)
+		} else {
+			header.Set(HeaderETag, fmt.Sprintf(`W/"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+		}
+	}
+
+	http.ServeContent(c.Writer, c.Request, info.Name(), info.ModTime(), content)
+	return nil
+}
+
+func contentETag(content io.ReadSeeker) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`, nil
+}
+
+func listDirectory(c *Context, fsys fs.FS, name string) error {
+	entries, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		return err
+	}
+
+	base := c.Request.URL.Path
+	if base == "" || base[len(base)-1] != '/' {
+		base += "/"
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	buf.WriteString("<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<pre>\n")
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		link := url.URL{Path: base + entryName}
+		fmt.Fprintf(buf, "<a href=\"%s\">%s</a>\n", html.EscapeString(link.EscapedPath()), html.EscapeString(entryName))
+	}
+	buf.WriteString("</pre>\n")
+
+	return c.Blob(http.StatusOK, MIMETextHTMLCharsetUTF8, buf.Bytes())
+}
+
+// cleanFSPath turns a URL path into an fs.FS name, rejecting anything that
+// could escape the root.
+func cleanFSPath(p string) (string, bool) {
+	name := path.Clean("/" + p)[1:]
+	if name == "" {
+		name = "."
+	}
+	return name, fs.ValidPath(name)
+}
+
+func fsError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, fs.ErrNotExist):
+		return NewHTTPError(http.StatusNotFound, http.StatusText(http.StatusNotFound))
+	case errors.Is(err, fs.ErrPermission):
+		return NewHTTPError(http.StatusForbidden, http.StatusText(http.StatusForbidden))
+	}
+	return err
+}
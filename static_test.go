@@ -0,0 +1,121 @@
+package teta
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newStaticDir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html":   "<h1>home</h1>",
+		"app.js":       "console.log(1)",
+		"docs/a b.txt": "notes",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func serveStatic(r *Router, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestStaticRejectsTraversal(t *testing.T) {
+	dir := newStaticDir(t)
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(outside, []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "link.txt")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	r := New()
+	r.SetLogger(newLogger(io.Discard))
+	r.Static("/static", dir)
+
+	if w := serveStatic(r, "/static/app.js", nil); w.Code != http.StatusOK || w.Body.String() != "console.log(1)" {
+		t.Fatalf("app.js: status = %d, body = %q", w.Code, w.Body.String())
+	}
+	if w := serveStatic(r, "/static/link.txt", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("symlink out of the root: status = %d, want 404", w.Code)
+	}
+
+	for _, p := range []string{"../secret.txt", "a/../../secret.txt", "/../../etc/passwd"} {
+		if name, ok := cleanFSPath(p); ok && strings.Contains(name, "..") {
+			t.Errorf("cleanFSPath(%q) = %q, escapes the root", p, name)
+		}
+	}
+}
+
+func TestStaticSPAFallback(t *testing.T) {
+	dir := newStaticDir(t)
+
+	r := New()
+	r.StaticWithConfig("/", StaticConfig{Filesystem: os.DirFS(dir), SPA: true})
+
+	w := serveStatic(r, "/settings/profile", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "<h1>home</h1>" {
+		t.Fatalf("status = %d, body = %q; want the index", w.Code, w.Body.String())
+	}
+}
+
+func TestStaticBrowse(t *testing.T) {
+	dir := newStaticDir(t)
+
+	r := New()
+	r.StaticWithConfig("/files", StaticConfig{Filesystem: os.DirFS(dir), Browse: true})
+
+	w := serveStatic(r, "/files/docs/", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<a href="/files/docs/a%20b.txt">a b.txt</a>`) {
+		t.Fatalf("status = %d, body = %q", w.Code, w.Body.String())
+	}
+
+	r = New()
+	r.SetLogger(newLogger(io.Discard))
+	r.StaticWithConfig("/files", StaticConfig{Filesystem: os.DirFS(dir)})
+	if w := serveStatic(r, "/files/docs/", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("listing disabled: status = %d, want 404", w.Code)
+	}
+}
+
+func TestStaticConditionalRequests(t *testing.T) {
+	dir := newStaticDir(t)
+
+	r := New()
+	r.Static("/static", dir)
+
+	w := serveStatic(r, "/static/app.js", nil)
+	etag, modified := w.Header().Get(HeaderETag), w.Header().Get(HeaderLastModified)
+	if etag == "" || modified == "" {
+		t.Fatalf("ETag = %q, Last-Modified = %q", etag, modified)
+	}
+
+	if w := serveStatic(r, "/static/app.js", http.Header{HeaderIfNoneMatch: {etag}}); w.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match: status = %d, want 304", w.Code)
+	}
+	if w := serveStatic(r, "/static/app.js", http.Header{HeaderIfModifiedSince: {modified}}); w.Code != http.StatusNotModified {
+		t.Errorf("If-Modified-Since: status = %d, want 304", w.Code)
+	}
+}
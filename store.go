@@ -0,0 +1,125 @@
+package teta
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// contextStore holds the values set on a Context. It is a context.Context
+// itself: once the first value is set it becomes the request context, so
+// lookups through c.Request.Context() hit the map in O(1) before falling
+// back to the parent chain. A store is allocated per request and never
+// reused, so a request context kept past the handler only ever sees the
+// values of its own request. The maps are guarded, as context values may be
+// read from other goroutines while the handler sets more.
+type contextStore struct {
+	mu     sync.RWMutex
+	parent context.Context
+	values map[string]any
+	typed  map[any]any
+}
+
+func newContextStore(parent context.Context) *contextStore {
+	return &contextStore{parent: parent}
+}
+
+func (s *contextStore) set(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.values == nil {
+		s.values = make(map[string]any, 8)
+	}
+	s.values[key] = value
+}
+
+func (s *contextStore) get(key string) (any, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	v, ok := s.values[key]
+	return v, ok
+}
+
+func (s *contextStore) setTyped(key, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.typed == nil {
+		s.typed = make(map[any]any, 4)
+	}
+	s.typed[key] = value
+}
+
+func (s *contextStore) getTyped(key any) (any, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	v, ok := s.typed[key]
+	return v, ok
+}
+
+func (s *contextStore) parentContext() context.Context {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.parent
+}
+
+func (s *contextStore) setParent(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.parent = ctx
+}
+
+type contextStoreKey struct{}
+
+// wrap returns the context to install on the request for ctx. A ctx derived
+// from the store already sees its values; any other ctx becomes the parent
+// of the store, so values flow both ways.
+func (s *contextStore) wrap(ctx context.Context) context.Context {
+	if ctx.Value(contextStoreKey{}) == s {
+		return ctx
+	}
+
+	s.setParent(ctx)
+	return s
+}
+
+func (s *contextStore) Deadline() (time.Time, bool) {
+	return s.parentContext().Deadline()
+}
+
+func (s *contextStore) Done() <-chan struct{} {
+	return s.parentContext().Done()
+}
+
+func (s *contextStore) Err() error {
+	return s.parentContext().Err()
+}
+
+func (s *contextStore) Value(key any) any {
+	if key == (contextStoreKey{}) {
+		return s
+	}
+
+	s.mu.RLock()
+	var (
+		v  any
+		ok bool
+	)
+	if k, isName := key.(ContextKey); isName {
+		v, ok = s.values[k.key]
+	} else if len(s.typed) > 0 {
+		v, ok = s.typed[key]
+	}
+	parent := s.parent
+	s.mu.RUnlock()
+
+	if ok {
+		return v
+	}
+	return parent.Value(key)
+}
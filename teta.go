@@ -3,9 +3,10 @@ package teta
 import (
 	"context"
 	"encoding/json"
-	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
+	"sync"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -13,16 +14,34 @@ import (
 type Router struct {
 	*RouterGroup
 	Logger
+
+	encoders       *EncoderRegistry
+	jsonSerializer JSONSerializer
+	debug          bool
+	renderer       Renderer
+
+	routesMu sync.RWMutex
+	routes   map[string]*Route
+
+	preflightMu sync.RWMutex
+	preflight   map[string]http.Handler
+	methods     []string
+
+	trustedProxies []netip.Prefix
 }
 
 func New() *Router {
 	defaultValidator := NewDefaultValidator()
 	errorHandler := defaultHTTPErrorHandler
 
-	return &Router{
-		RouterGroup: newRouteGroup(defaultValidator, errorHandler),
-		Logger:      newLogger(os.Stdout),
+	t := &Router{
+		Logger:         newLogger(os.Stdout),
+		encoders:       NewEncoderRegistry(),
+		jsonSerializer: defaultJSONSerializer,
 	}
+	t.RouterGroup = newRouteGroup(t, defaultValidator, errorHandler)
+
+	return t
 }
 
 func (t *Router) SetCustomValidator(v Validator) {
@@ -33,6 +52,37 @@ func (t *Router) SetCustomHTTPErrorHandler(handler HTTPErrorHandler) {
 	t.httpErrorHandler = handler
 }
 
+// SetLogger replaces the logger used by the router and by Context.Logger.
+func (t *Router) SetLogger(l Logger) {
+	t.Logger = l
+}
+
+// SetDebug enables development behaviour such as indented JSON responses.
+func (t *Router) SetDebug(debug bool) {
+	t.debug = debug
+}
+
+// SetJSONSerializer replaces the codec used by Context.JSON, request binding
+// and the application/json entry of the encoder registry.
+func (t *Router) SetJSONSerializer(s JSONSerializer) {
+	t.jsonSerializer = s
+	t.encoders.Register(MIMEApplicationJSON, serializerEncoder{serializer: s})
+}
+
+// SetRenderer sets the Renderer used by Context.Render. Renderers that
+// resolve routes, like HTMLRenderer, are bound to the router.
+func (t *Router) SetRenderer(r Renderer) {
+	if binder, ok := r.(interface{ bindRouter(*Router) }); ok {
+		binder.bindRouter(t)
+	}
+	t.renderer = r
+}
+
+// Encoders returns the registry used by Context.Negotiate.
+func (t *Router) Encoders() *EncoderRegistry {
+	return t.encoders
+}
+
 func defaultHTTPErrorHandler(err error, c *Context) {
 	w := c.Writer
 	r := c.Request
@@ -45,16 +95,19 @@ func defaultHTTPErrorHandler(err error, c *Context) {
 		}
 	}
 
-	slog.Error(
+	c.Logger().Error(
 		"Server error",
 		"error", httpErr,
 		"path", r.URL.Path,
-		"method", r.Method,
-		"ip", r.RemoteAddr,
+		"ip", c.RealIP(),
 		"code", httpErr.code,
 	)
 
-	w.Header().Set("Content-Type", "application/json")
+	if c.Response().Committed {
+		return
+	}
+
+	w.Header().Set(HeaderContentType, MIMEApplicationJSON)
 	w.WriteHeader(httpErr.code)
 	json.NewEncoder(w).Encode(&HTTPErrorMessage{httpErr.message})
 }
@@ -82,11 +135,11 @@ type Validator interface {
 }
 
 func (t *Router) Start(addr string) error {
-	return http.ListenAndServe(addr, t.handler)
+	return http.ListenAndServe(addr, t)
 }
 
 func (t *Router) StartTLS(addr, certFile, keyFile string) error {
-	return http.ListenAndServeTLS(addr, certFile, keyFile, t.handler)
+	return http.ListenAndServeTLS(addr, certFile, keyFile, t)
 }
 
 type StdMiddleware func(http.Handler) http.Handler
@@ -104,15 +157,34 @@ func FromStdMiddleware(middlewares ...StdMiddleware) Middleware {
 	mw := CreateStdStack(middlewares...)
 	return func(next HandlerFunc) HandlerFunc {
 		return func(c *Context) error {
-			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w, r := c.Writer, c.Request
+			var parent context.Context
+			if c.store != nil {
+				parent = c.store.parentContext()
+			}
+			defer func() {
 				c.Writer = w
 				c.Request = r
-				next(c)
+				// Values set inside the std chain stay, but the store no
+				// longer hangs off the std middleware's context.
+				if c.store != nil {
+					if parent != nil {
+						c.store.setParent(parent)
+					}
+					c.valueStore()
+				}
+			}()
+
+			var err error
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.Writer = w
+				c.adoptRequest(r)
+				err = next(c)
 			})
 
 			mw(handler).ServeHTTP(c.Writer, c.Request)
 
-			return nil
+			return err
 		}
 	}
 }
@@ -134,6 +206,7 @@ const (
 	MIMETextPlainCharsetUTF8             = MIMETextPlain + "; " + charsetUTF8
 	MIMEMultipartForm                    = "multipart/form-data"
 	MIMEOctetStream                      = "application/octet-stream"
+	MIMETextEventStream                  = "text/event-stream"
 )
 
 const (
@@ -156,12 +229,16 @@ const (
 	HeaderCookie              = "Cookie"
 	HeaderSetCookie           = "Set-Cookie"
 	HeaderIfModifiedSince     = "If-Modified-Since"
+	HeaderIfNoneMatch         = "If-None-Match"
+	HeaderETag                = "ETag"
 	HeaderLastModified        = "Last-Modified"
+	HeaderLastEventID         = "Last-Event-ID"
 	HeaderLocation            = "Location"
 	HeaderRetryAfter          = "Retry-After"
 	HeaderUpgrade             = "Upgrade"
 	HeaderVary                = "Vary"
 	HeaderWWWAuthenticate     = "WWW-Authenticate"
+	HeaderForwarded           = "Forwarded"
 	HeaderXForwardedFor       = "X-Forwarded-For"
 	HeaderXForwardedProto     = "X-Forwarded-Proto"
 	HeaderXForwardedProtocol  = "X-Forwarded-Protocol"
@@ -177,6 +254,12 @@ const (
 	HeaderCacheControl        = "Cache-Control"
 	HeaderConnection          = "Connection"
 
+	// WebSocket
+	HeaderSecWebSocketKey      = "Sec-WebSocket-Key"
+	HeaderSecWebSocketAccept   = "Sec-WebSocket-Accept"
+	HeaderSecWebSocketVersion  = "Sec-WebSocket-Version"
+	HeaderSecWebSocketProtocol = "Sec-WebSocket-Protocol"
+
 	// Access control
 	HeaderAccessControlRequestMethod    = "Access-Control-Request-Method"
 	HeaderAccessControlRequestHeaders   = "Access-Control-Request-Headers"
@@ -187,6 +270,12 @@ const (
 	HeaderAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
 	HeaderAccessControlMaxAge           = "Access-Control-Max-Age"
 
+	// Rate limiting
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRateLimitPolicy    = "RateLimit-Policy"
+
 	// Security
 	HeaderStrictTransportSecurity         = "Strict-Transport-Security"
 	HeaderXContentTypeOptions             = "X-Content-Type-Options"
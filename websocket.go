@@ -0,0 +1,626 @@
+package teta
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocket message types (RFC 6455 opcodes).
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+// WebSocket close codes (RFC 6455 section 7.4.1).
+const (
+	CloseNormalClosure      = 1000
+	CloseGoingAway          = 1001
+	CloseProtocolError      = 1002
+	CloseUnsupportedData    = 1003
+	CloseNoStatusReceived   = 1005
+	CloseAbnormalClosure    = 1006
+	CloseInvalidPayloadData = 1007
+	ClosePolicyViolation    = 1008
+	CloseMessageTooBig      = 1009
+	CloseInternalServerErr  = 1011
+)
+
+const (
+	websocketGUID              = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	defaultWebSocketReadLimit  = 1 << 20
+	defaultWebSocketCloseAfter = time.Second
+	defaultWebSocketPongWait   = 10 * time.Second
+	maxControlFramePayload     = 125
+)
+
+var (
+	ErrWebSocketReadLimit = errors.New("websocket: message exceeds read limit")
+	ErrWebSocketClosed    = errors.New("websocket: connection closed")
+)
+
+// CloseError is returned by ReadMessage once the peer has sent a close frame.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// IsCloseError reports whether err is a *CloseError with one of codes.
+func IsCloseError(err error, codes ...int) bool {
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) {
+		return false
+	}
+	for _, code := range codes {
+		if closeErr.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// WebSocketUpgrader performs the RFC 6455 opening handshake. The zero value
+// accepts same-origin requests with a 1 MiB message limit.
+type WebSocketUpgrader struct {
+	// Subprotocols lists the supported subprotocols in order of preference.
+	Subprotocols []string
+	// CheckOrigin reports whether the request Origin is acceptable. When nil,
+	// requests without an Origin header or with one matching Host are allowed.
+	CheckOrigin func(r *http.Request) bool
+	// ReadLimit is the maximum size of a reassembled message in bytes.
+	ReadLimit int64
+	// PingInterval enables server pings. The connection is dropped when
+	// nothing is received for PingInterval+PongTimeout; PongTimeout
+	// defaults to 10s.
+	PingInterval time.Duration
+	PongTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+// WebSocket returns a handler that upgrades the request and passes the
+// connection to fn. The handler runs through the usual middleware chain, and
+// the connection is closed when fn returns. A normal close by the peer,
+// returned from ReadMessage, is not an error.
+func WebSocket(u *WebSocketUpgrader, fn func(c *Context, ws *WebSocketConn) error) HandlerFunc {
+	return func(c *Context) error {
+		ws, err := u.Upgrade(c)
+		if err != nil {
+			return err
+		}
+
+		err = fn(c, ws)
+		if IsCloseError(err, CloseNormalClosure, CloseGoingAway, CloseNoStatusReceived) {
+			err = nil
+		}
+
+		code := CloseNormalClosure
+		if err != nil {
+			code = CloseInternalServerErr
+		}
+		ws.CloseWithReason(code, "")
+
+		return err
+	}
+}
+
+// Upgrade validates the handshake and hijacks the connection. Handshake
+// errors are returned as HTTPError so they reach the error handler.
+func (u *WebSocketUpgrader) Upgrade(c *Context) (*WebSocketConn, error) {
+	r := c.Request
+
+	if r.Method != http.MethodGet {
+		return nil, NewHTTPError(http.StatusMethodNotAllowed, "websocket: method must be GET")
+	}
+	if !headerContainsToken(r.Header, HeaderConnection, "upgrade") ||
+		!headerContainsToken(r.Header, HeaderUpgrade, "websocket") {
+		return nil, NewHTTPError(http.StatusBadRequest, "websocket: not a websocket handshake")
+	}
+	if r.Header.Get(HeaderSecWebSocketVersion) != "13" {
+		c.Writer.Header().Set(HeaderSecWebSocketVersion, "13")
+		return nil, NewHTTPError(http.StatusUpgradeRequired, "websocket: unsupported version")
+	}
+
+	key := r.Header.Get(HeaderSecWebSocketKey)
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, NewHTTPError(http.StatusBadRequest, "websocket: invalid Sec-WebSocket-Key")
+	}
+
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, NewHTTPError(http.StatusForbidden, "websocket: origin not allowed")
+	}
+
+	subprotocol := u.selectSubprotocol(r)
+
+	conn, brw, err := http.NewResponseController(c.Writer).Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("websocket: hijack failed: %w", err)
+	}
+	c.Response().Status = http.StatusSwitchingProtocols
+
+	// Deadlines set by http.Server must not leak into the upgraded connection.
+	conn.SetDeadline(time.Time{})
+
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	b.WriteString("Upgrade: websocket\r\n")
+	b.WriteString("Connection: Upgrade\r\n")
+	b.WriteString(HeaderSecWebSocketAccept + ": " + websocketAccept(key) + "\r\n")
+	if subprotocol != "" {
+		b.WriteString(HeaderSecWebSocketProtocol + ": " + subprotocol + "\r\n")
+	}
+	b.WriteString("\r\n")
+
+	if _, err := brw.WriteString(b.String()); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	readLimit := u.ReadLimit
+	if readLimit <= 0 {
+		readLimit = defaultWebSocketReadLimit
+	}
+
+	ws := &WebSocketConn{
+		conn:          conn,
+		br:            brw.Reader,
+		bw:            brw.Writer,
+		serializer:    c.JSONSerializer(),
+		subprotocol:   subprotocol,
+		readLimit:     readLimit,
+		writeTimeout:  u.WriteTimeout,
+		done:          make(chan struct{}),
+		closeReceived: make(chan struct{}),
+	}
+
+	if u.PingInterval > 0 {
+		pongTimeout := u.PongTimeout
+		if pongTimeout <= 0 {
+			pongTimeout = defaultWebSocketPongWait
+		}
+		ws.idleTimeout = u.PingInterval + pongTimeout
+		ws.extendReadDeadline()
+		go ws.pingLoop(u.PingInterval)
+	}
+
+	return ws, nil
+}
+
+func (u *WebSocketUpgrader) selectSubprotocol(r *http.Request) string {
+	if len(u.Subprotocols) == 0 {
+		return ""
+	}
+
+	var offered []string
+	for _, value := range r.Header.Values(HeaderSecWebSocketProtocol) {
+		for p := range strings.SplitSeq(value, ",") {
+			offered = append(offered, strings.TrimSpace(p))
+		}
+	}
+
+	for _, supported := range u.Subprotocols {
+		for _, p := range offered {
+			if p == supported {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get(HeaderOrigin)
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func websocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key))
+	h.Write([]byte(websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for t := range strings.SplitSeq(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WebSocketConn is a server side WebSocket connection. One goroutine may read
+// while others write; writes are serialized internally.
+type WebSocketConn struct {
+	conn         net.Conn
+	br           *bufio.Reader
+	bw           *bufio.Writer
+	serializer   JSONSerializer
+	subprotocol  string
+	readLimit    int64
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
+
+	readMu sync.Mutex
+
+	writeMu   sync.Mutex
+	closeSent bool
+
+	closeOnce     sync.Once
+	closeRecvOnce sync.Once
+	done          chan struct{}
+	closeReceived chan struct{}
+}
+
+func (ws *WebSocketConn) Subprotocol() string {
+	return ws.subprotocol
+}
+
+func (ws *WebSocketConn) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+func (ws *WebSocketConn) SetReadLimit(limit int64) {
+	ws.readLimit = limit
+}
+
+func (ws *WebSocketConn) SetReadDeadline(t time.Time) error {
+	return ws.conn.SetReadDeadline(t)
+}
+
+// ReadMessage returns the next text or binary message. Pings are answered
+// and fragmented messages are reassembled transparently. After the peer
+// closes the connection a *CloseError is returned.
+func (ws *WebSocketConn) ReadMessage() (messageType int, p []byte, err error) {
+	ws.readMu.Lock()
+	defer ws.readMu.Unlock()
+
+	var message []byte
+
+	for {
+		fin, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case PingMessage:
+			if err := ws.writeFrame(PongMessage, payload); err != nil && !errors.Is(err, ErrWebSocketClosed) {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			ws.extendReadDeadline()
+			continue
+		case CloseMessage:
+			return 0, nil, ws.handleClose(payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "unexpected data frame")
+			}
+			messageType = opcode
+		case 0:
+			if messageType == 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			return 0, nil, ws.fail(CloseProtocolError, "unknown opcode")
+		}
+
+		if int64(len(message)+len(payload)) > ws.readLimit {
+			ws.fail(CloseMessageTooBig, "")
+			return 0, nil, ErrWebSocketReadLimit
+		}
+		message = append(message, payload...)
+
+		if fin {
+			break
+		}
+	}
+
+	if messageType == TextMessage && !utf8.Valid(message) {
+		return 0, nil, ws.fail(CloseInvalidPayloadData, "invalid utf-8")
+	}
+
+	return messageType, message, nil
+}
+
+func (ws *WebSocketConn) ReadJSON(v any) error {
+	_, p, err := ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return ws.serializer.Deserialize(bytes.NewReader(p), v)
+}
+
+func (ws *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	return ws.writeFrame(messageType, data)
+}
+
+func (ws *WebSocketConn) WriteJSON(v any) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if err := ws.serializer.Serialize(buf, v, ""); err != nil {
+		return err
+	}
+	return ws.writeFrame(TextMessage, buf.Bytes())
+}
+
+func (ws *WebSocketConn) Ping(data []byte) error {
+	if len(data) > maxControlFramePayload {
+		return errors.New("websocket: control frame payload too large")
+	}
+	return ws.writeFrame(PingMessage, data)
+}
+
+func (ws *WebSocketConn) Close() error {
+	return ws.CloseWithReason(CloseNormalClosure, "")
+}
+
+// CloseWithReason performs the closing handshake: it sends a close frame,
+// waits briefly for the peer's close frame and closes the connection.
+func (ws *WebSocketConn) CloseWithReason(code int, reason string) error {
+	err := ws.writeClose(code, reason)
+
+	// Drain frames ourselves unless a reader is active; the reader will see
+	// the peer's close frame and signal closeReceived instead. Nothing is
+	// left to wait for when the peer closed first.
+	deadline := time.Now().Add(defaultWebSocketCloseAfter)
+	ws.conn.SetReadDeadline(deadline)
+	select {
+	case <-ws.closeReceived:
+	default:
+		ws.awaitClose(deadline)
+	}
+
+	ws.shutdown()
+	if errors.Is(err, ErrWebSocketClosed) {
+		return nil
+	}
+	return err
+}
+
+// awaitClose reads until the peer's close frame or the deadline.
+func (ws *WebSocketConn) awaitClose(deadline time.Time) {
+	if !ws.readMu.TryLock() {
+		select {
+		case <-ws.closeReceived:
+		case <-time.After(time.Until(deadline)):
+		}
+		return
+	}
+	defer ws.readMu.Unlock()
+
+	for {
+		_, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return
+		}
+		if opcode == CloseMessage {
+			ws.handleClose(payload)
+			return
+		}
+	}
+}
+
+func (ws *WebSocketConn) shutdown() {
+	ws.closeOnce.Do(func() {
+		close(ws.done)
+		ws.conn.Close()
+	})
+}
+
+func (ws *WebSocketConn) handleClose(payload []byte) error {
+	ws.closeRecvOnce.Do(func() { close(ws.closeReceived) })
+
+	closeErr := &CloseError{Code: CloseNoStatusReceived}
+	switch {
+	case len(payload) == 1:
+		return ws.fail(CloseProtocolError, "invalid close payload")
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Text = string(payload[2:])
+		if !validCloseCode(closeErr.Code) || !utf8.ValidString(closeErr.Text) {
+			return ws.fail(CloseProtocolError, "invalid close payload")
+		}
+	}
+
+	reply := closeErr.Code
+	if reply == CloseNoStatusReceived {
+		reply = CloseNormalClosure
+	}
+	ws.writeClose(reply, "")
+
+	return closeErr
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code >= 1000 && code <= 1011:
+		return code != 1004 && code != CloseNoStatusReceived && code != CloseAbnormalClosure
+	}
+	return false
+}
+
+// fail sends a close frame for a protocol violation and returns the matching
+// error for the reader.
+func (ws *WebSocketConn) fail(code int, reason string) error {
+	ws.writeClose(code, reason)
+	return &CloseError{Code: code, Text: reason}
+}
+
+func (ws *WebSocketConn) writeClose(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > maxControlFramePayload {
+		payload = payload[:maxControlFramePayload]
+	}
+
+	return ws.writeFrame(CloseMessage, payload)
+}
+
+func (ws *WebSocketConn) readFrame() (fin bool, opcode int, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(ws.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin = header[0]&0x80 != 0
+	opcode = int(header[0] & 0x0f)
+	masked := header[1]&0x80 != 0
+	length := int64(header[1] & 0x7f)
+
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, ws.fail(CloseProtocolError, "reserved bits set")
+	}
+	if !masked {
+		return false, 0, nil, ws.fail(CloseProtocolError, "client frames must be masked")
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(ws.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(ws.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+		if length < 0 {
+			return false, 0, nil, ws.fail(CloseProtocolError, "invalid length")
+		}
+	}
+
+	if opcode >= CloseMessage && (!fin || length > maxControlFramePayload) {
+		return false, 0, nil, ws.fail(CloseProtocolError, "invalid control frame")
+	}
+	if length > ws.readLimit {
+		ws.fail(CloseMessageTooBig, "")
+		return false, 0, nil, ErrWebSocketReadLimit
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(ws.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(ws.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	ws.extendReadDeadline()
+	return fin, opcode, payload, nil
+}
+
+func (ws *WebSocketConn) writeFrame(opcode int, payload []byte) error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+
+	if ws.closeSent {
+		return ErrWebSocketClosed
+	}
+	if opcode == CloseMessage {
+		ws.closeSent = true
+	}
+
+	if ws.writeTimeout > 0 {
+		ws.conn.SetWriteDeadline(time.Now().Add(ws.writeTimeout))
+	}
+
+	var header [10]byte
+	header[0] = 0x80 | byte(opcode)
+	n := 2
+	switch length := len(payload); {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xffff:
+		header[1] = 126
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+		n += 2
+	default:
+		header[1] = 127
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+		n += 8
+	}
+
+	if _, err := ws.bw.Write(header[:n]); err != nil {
+		return err
+	}
+	if _, err := ws.bw.Write(payload); err != nil {
+		return err
+	}
+	return ws.bw.Flush()
+}
+
+func (ws *WebSocketConn) extendReadDeadline() {
+	if ws.idleTimeout > 0 {
+		ws.conn.SetReadDeadline(time.Now().Add(ws.idleTimeout))
+	}
+}
+
+func (ws *WebSocketConn) pingLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := ws.writeFrame(PingMessage, nil); err != nil {
+				return
+			}
+		case <-ws.done:
+			return
+		}
+	}
+}
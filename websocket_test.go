@@ -0,0 +1,236 @@
+package teta
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testWebSocketKey = "dGhlIHNhbXBsZSBub25jZQ=="
+
+// newWebSocketServer serves an echo handler and reports what it returns.
+func newWebSocketServer(t *testing.T, u *WebSocketUpgrader) (*httptest.Server, <-chan error) {
+	t.Helper()
+
+	errs := make(chan error, 1)
+	r := New()
+	r.SetLogger(newLogger(io.Discard))
+	r.Use(func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			err := next(c)
+			errs <- err
+			return err
+		}
+	})
+	r.Get("/ws", WebSocket(u, func(c *Context, ws *WebSocketConn) error {
+		for {
+			mt, p, err := ws.ReadMessage()
+			if err != nil {
+				return err
+			}
+			if err := ws.WriteMessage(mt, p); err != nil {
+				return err
+			}
+		}
+	}))
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv, errs
+}
+
+type testWebSocketClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dialWebSocket(t *testing.T, srv *httptest.Server, header http.Header) (*testWebSocketClient, *http.Response) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/ws", nil)
+	req.Header.Set(HeaderConnection, "Upgrade")
+	req.Header.Set(HeaderUpgrade, "websocket")
+	req.Header.Set(HeaderSecWebSocketVersion, "13")
+	req.Header.Set(HeaderSecWebSocketKey, testWebSocketKey)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testWebSocketClient{conn: conn, br: br}, res
+}
+
+func (c *testWebSocketClient) writeFrame(t *testing.T, fin bool, opcode int, payload []byte) {
+	t.Helper()
+
+	b := byte(opcode)
+	if fin {
+		b |= 0x80
+	}
+	frame := []byte{b, 0x80 | byte(len(payload))}
+	if len(payload) > 125 {
+		t.Fatal("test frames are limited to 125 bytes")
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, p := range payload {
+		frame = append(frame, p^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *testWebSocketClient) readFrame(t *testing.T) (int, []byte) {
+	t.Helper()
+
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, header[1]&0x7f)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return int(header[0] & 0x0f), payload
+}
+
+func closePayload(code int) []byte {
+	return binary.BigEndian.AppendUint16(nil, uint16(code))
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	srv, _ := newWebSocketServer(t, &WebSocketUpgrader{Subprotocols: []string{"chat"}})
+
+	_, res := dialWebSocket(t, srv, http.Header{HeaderSecWebSocketProtocol: {"superchat, chat"}})
+
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", res.StatusCode)
+	}
+	if got := res.Header.Get(HeaderSecWebSocketAccept); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Sec-WebSocket-Accept = %q", got)
+	}
+	if got := res.Header.Get(HeaderSecWebSocketProtocol); got != "chat" {
+		t.Errorf("Sec-WebSocket-Protocol = %q, want chat", got)
+	}
+}
+
+func TestWebSocketFragmentedText(t *testing.T) {
+	srv, _ := newWebSocketServer(t, &WebSocketUpgrader{})
+	ws, _ := dialWebSocket(t, srv, nil)
+
+	ws.writeFrame(t, false, TextMessage, []byte("hel"))
+	ws.writeFrame(t, true, 0, []byte("lo"))
+
+	opcode, payload := ws.readFrame(t)
+	if opcode != TextMessage || string(payload) != "hello" {
+		t.Fatalf("got opcode %d %q, want text %q", opcode, payload, "hello")
+	}
+}
+
+func TestWebSocketOversizeMessage(t *testing.T) {
+	srv, errs := newWebSocketServer(t, &WebSocketUpgrader{ReadLimit: 8})
+	ws, _ := dialWebSocket(t, srv, nil)
+
+	ws.writeFrame(t, false, TextMessage, []byte("12345"))
+	ws.writeFrame(t, true, 0, []byte("67890"))
+
+	opcode, payload := ws.readFrame(t)
+	if opcode != CloseMessage || binary.BigEndian.Uint16(payload) != CloseMessageTooBig {
+		t.Fatalf("got opcode %d %v, want close 1009", opcode, payload)
+	}
+	if err := <-errs; err != ErrWebSocketReadLimit {
+		t.Fatalf("handler returned %v, want ErrWebSocketReadLimit", err)
+	}
+}
+
+func TestWebSocketPingPong(t *testing.T) {
+	srv, _ := newWebSocketServer(t, &WebSocketUpgrader{})
+	ws, _ := dialWebSocket(t, srv, nil)
+
+	ws.writeFrame(t, true, PingMessage, []byte("are you there"))
+
+	opcode, payload := ws.readFrame(t)
+	if opcode != PongMessage || string(payload) != "are you there" {
+		t.Fatalf("got opcode %d %q, want pong with the ping payload", opcode, payload)
+	}
+}
+
+func TestWebSocketCloseEcho(t *testing.T) {
+	for _, code := range []int{CloseNormalClosure, CloseGoingAway} {
+		srv, errs := newWebSocketServer(t, &WebSocketUpgrader{})
+		ws, _ := dialWebSocket(t, srv, nil)
+
+		ws.writeFrame(t, true, CloseMessage, closePayload(code))
+
+		opcode, payload := ws.readFrame(t)
+		if opcode != CloseMessage || int(binary.BigEndian.Uint16(payload)) != code {
+			t.Fatalf("got opcode %d %v, want close %d", opcode, payload, code)
+		}
+		if err := <-errs; err != nil {
+			t.Fatalf("close %d: handler returned %v, want nil", code, err)
+		}
+	}
+}
+
+func TestWebSocketBadOrigin(t *testing.T) {
+	srv, _ := newWebSocketServer(t, &WebSocketUpgrader{})
+
+	_, res := dialWebSocket(t, srv, http.Header{HeaderOrigin: {"https://evil.example"}})
+
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", res.StatusCode)
+	}
+	body, _ := io.ReadAll(res.Body)
+	if !strings.Contains(string(body), "origin not allowed") {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestWebSocketServerPingKeepsConnection(t *testing.T) {
+	srv, _ := newWebSocketServer(t, &WebSocketUpgrader{PingInterval: 20 * time.Millisecond})
+	ws, _ := dialWebSocket(t, srv, nil)
+
+	pings := 0
+	for pings < 5 {
+		opcode, payload := ws.readFrame(t)
+		if opcode != PingMessage {
+			t.Fatalf("got opcode %d, want ping", opcode)
+		}
+		pings++
+		ws.writeFrame(t, true, PongMessage, payload)
+	}
+
+	ws.writeFrame(t, true, TextMessage, []byte("still here"))
+	for {
+		opcode, payload := ws.readFrame(t)
+		if opcode == PingMessage {
+			ws.writeFrame(t, true, PongMessage, payload)
+			continue
+		}
+		if opcode != TextMessage || string(payload) != "still here" {
+			t.Fatalf("got opcode %d %q, want the echoed message", opcode, payload)
+		}
+		return
+	}
+}